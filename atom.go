@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type AtomFeed struct {
+	Title           AtomText     `xml:"title"`
+	Subtitle        AtomText     `xml:"subtitle"`
+	Link            []AtomLink   `xml:"link"`
+	UpdatePeriod    string       `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string       `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	Author          []AtomPerson `xml:"author"`
+	Entry           []AtomEntry  `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID        string       `xml:"id"`
+	Title     AtomText     `xml:"title"`
+	Link      []AtomLink   `xml:"link"`
+	Published string       `xml:"published"`
+	Updated   string       `xml:"updated"`
+	Summary   AtomText     `xml:"summary"`
+	Content   AtomText     `xml:"content"`
+	Author    []AtomPerson `xml:"author"`
+}
+
+type AtomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+// AtomPerson is an Atom person construct, such as an <author>.
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+// AtomText is an Atom text construct. Type is "text", "html" or "xhtml";
+// for xhtml the payload is markup, so the inner XML is kept as-is.
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Body     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+// String returns the text content, using the raw markup for xhtml
+// constructs and unescaping entities for the others.
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return html.UnescapeString(strings.TrimSpace(t.Body))
+}
+
+// alternateLink picks the link an entry points at: rel="alternate", which
+// is also the meaning of a link without a rel attribute.
+func alternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// atomAuthorNames returns the names of a list of Atom persons.
+func atomAuthorNames(people []AtomPerson) []string {
+	names := make([]string, 0, len(people))
+	for _, person := range people {
+		names = append(names, person.Name)
+	}
+	return nonEmpty(names)
+}
+
+func parseAtom(body []byte) (*Feed, error) {
+	var atom AtomFeed
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+	}
+
+	feed := &Feed{
+		Title:          atom.Title.String(),
+		Link:           alternateLink(atom.Link),
+		Description:    atom.Subtitle.String(),
+		UpdateInterval: syndicationInterval(atom.UpdatePeriod, atom.UpdateFrequency),
+		Items:          make([]FeedItem, 0, len(atom.Entry)),
+	}
+	feedAuthors := atomAuthorNames(atom.Author)
+	for _, entry := range atom.Entry {
+		description := entry.Summary.String()
+		if description == "" {
+			description = entry.Content.String()
+		}
+
+		pubDate := entry.Published
+		if pubDate == "" {
+			pubDate = entry.Updated
+		}
+
+		// Entries inherit the feed's authors when they don't name their own.
+		authors := atomAuthorNames(entry.Author)
+		if len(authors) == 0 {
+			authors = feedAuthors
+		}
+
+		link := alternateLink(entry.Link)
+		feed.Items = append(feed.Items, FeedItem{
+			ID:          strings.TrimSpace(entry.ID),
+			Title:       entry.Title.String(),
+			Link:        link,
+			RawLink:     link,
+			Description: description,
+			PubDate:     strings.TrimSpace(pubDate),
+			Authors:     authors,
+		})
+	}
+
+	return feed, nil
+}
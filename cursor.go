@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/google/uuid"
+)
+
+// browseCursor marks the last post of a browse page, so the next page
+// can continue after it even as new posts arrive. It is handed to users
+// as an opaque string.
+type browseCursor struct {
+	Sort     string    `json:"s"`
+	FeedName string    `json:"f,omitempty"`
+	SortTime time.Time `json:"t"`
+	ID       uuid.UUID `json:"id"`
+}
+
+// newBrowseCursor returns the cursor for the page that follows post.
+func newBrowseCursor(sort string, post database.GetPostsForUserRow) browseCursor {
+	return browseCursor{
+		Sort:     sort,
+		FeedName: post.FeedName,
+		SortTime: post.SortTime,
+		ID:       post.ID,
+	}
+}
+
+func (c browseCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// parseBrowseCursor decodes a cursor produced by browseCursor.String for
+// the given sort order.
+func parseBrowseCursor(value, sort string) (browseCursor, error) {
+	var c browseCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor: %s", value)
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor: %s", value)
+	}
+	if c.Sort != sort {
+		return c, fmt.Errorf("cursor was made for --sort %s, not %s", c.Sort, sort)
+	}
+	return c, nil
+}
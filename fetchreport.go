@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/google/uuid"
+)
+
+// postOutcome is what savePost did with a single feed item.
+type postOutcome int
+
+const (
+	postInserted postOutcome = iota
+	postUpdated
+	postDuplicate
+	postFailed
+)
+
+func (o postOutcome) String() string {
+	switch o {
+	case postInserted:
+		return "inserted"
+	case postUpdated:
+		return "updated"
+	case postDuplicate:
+		return "duplicate"
+	default:
+		return "failed"
+	}
+}
+
+// Values of feed_fetches.status.
+const (
+	fetchStatusOK          = "ok"
+	fetchStatusNotModified = "not_modified"
+	fetchStatusFailed      = "failed"
+)
+
+// fetchReport summarises a single fetch of a feed: whether it succeeded
+// and what happened to each of its items.
+type fetchReport struct {
+	feedName   string
+	startedAt  time.Time
+	finishedAt time.Time
+	status     string
+	items      int
+	inserted   int
+	updated    int
+	duplicates int
+	failed     int
+	// err is why the fetch as a whole failed.
+	err error
+	// itemErrs are the errors of items that failed to save.
+	itemErrs []error
+}
+
+// add counts the outcome of saving one item.
+func (r *fetchReport) add(outcome postOutcome, err error) {
+	r.items++
+	switch outcome {
+	case postInserted:
+		r.inserted++
+	case postUpdated:
+		r.updated++
+	case postDuplicate:
+		r.duplicates++
+	default:
+		r.failed++
+		r.itemErrs = append(r.itemErrs, err)
+	}
+}
+
+// errorText is what gets stored in feed_fetches.error: the fetch error,
+// or the first item error along with how many items failed.
+func (r *fetchReport) errorText() string {
+	if r.err != nil {
+		return r.err.Error()
+	}
+	if len(r.itemErrs) == 0 {
+		return ""
+	}
+	if len(r.itemErrs) == 1 {
+		return r.itemErrs[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more)", r.itemErrs[0], len(r.itemErrs)-1)
+}
+
+func (r *fetchReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "feed %s: %s in %s", r.feedName, r.status,
+		r.finishedAt.Sub(r.startedAt).Round(time.Millisecond))
+	if r.status == fetchStatusOK {
+		fmt.Fprintf(&b, ", %d items: %d inserted, %d updated, %d duplicates, %d failed",
+			r.items, r.inserted, r.updated, r.duplicates, r.failed)
+	}
+	if text := r.errorText(); text != "" {
+		fmt.Fprintf(&b, ": %s", text)
+	}
+	return b.String()
+}
+
+// saveFetchReport logs a finished fetch and records it in feed_fetches.
+func saveFetchReport(ctx context.Context, s *state, feedID uuid.UUID, r *fetchReport) {
+	log.Print(r)
+
+	text := r.errorText()
+	err := s.db.CreateFeedFetch(ctx, database.CreateFeedFetchParams{
+		ID:         uuid.New(),
+		FeedID:     feedID,
+		StartedAt:  r.startedAt,
+		FinishedAt: r.finishedAt,
+		Status:     r.status,
+		Items:      int32(r.items),
+		Inserted:   int32(r.inserted),
+		Updated:    int32(r.updated),
+		Duplicates: int32(r.duplicates),
+		Failed:     int32(r.failed),
+		Error: sql.NullString{
+			String: text,
+			Valid:  text != "",
+		},
+	})
+	if err != nil {
+		log.Printf("error recording fetch of feed %s: %v", r.feedName, err)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"github.com/akigithub888/aggreGATOR/internal/config"
+)
+
+// extractGlobalFlags removes the flags every command accepts from args,
+// so that individual handlers never see them. They may appear anywhere
+// on the command line.
+func extractGlobalFlags(args []string) (config.Options, []string) {
+	var opts config.Options
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		if i+1 >= len(args) {
+			rest = append(rest, args[i])
+			continue
+		}
+		switch args[i] {
+		case "--config":
+			opts.Path = args[i+1]
+		case "--profile":
+			opts.Profile = args[i+1]
+		case "--db-url":
+			opts.DBURL = args[i+1]
+		case "--output":
+			opts.Output = args[i+1]
+		default:
+			rest = append(rest, args[i])
+			continue
+		}
+		i++ // skip the value
+	}
+	return opts, rest
+}
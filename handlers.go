@@ -1,23 +1,33 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/akigithub888/aggreGATOR/internal/config"
 	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/akigithub888/aggreGATOR/internal/storage"
 	"github.com/google/uuid"
 )
 
 type state struct {
-	db  *database.Queries
-	cfg *config.Config
+	db     storage.Store
+	conn   *storage.DB
+	cfg    *config.Config
+	output outputFormat
 }
 
 type command struct {
@@ -26,10 +36,277 @@ type command struct {
 }
 
 type commands struct {
-	handlers map[string]func(*state, command) error
+	handlers map[string]func(context.Context, *state, command) error
 }
 
-func handlerBrowse(s *state, cmd command, user database.GetUserByNameRow) error {
+func handlerBrowse(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	params := database.GetPostsForUserParams{
+		UserID:     user.ID,
+		UnreadOnly: true,
+		Sort:       "published",
+		Feeds:      []string{},
+		Limit:      2,
+	}
+	markRead := false
+	after := ""
+	now := time.Now()
+
+	// parse --limit, --offset, --after, --sort, --feed, --since, --until,
+	// --unread, --all and --mark-read flags
+	for i := 0; i < len(cmd.args); i++ {
+		switch {
+		case cmd.args[i] == "--limit" && i+1 < len(cmd.args):
+			l, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid limit: %v", err)
+			}
+			params.Limit = int32(l)
+			i++ // skip the value
+		case cmd.args[i] == "--offset" && i+1 < len(cmd.args):
+			o, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil || o < 0 {
+				return fmt.Errorf("invalid offset: %s", cmd.args[i+1])
+			}
+			params.Offset = int32(o)
+			i++ // skip the value
+		case cmd.args[i] == "--after" && i+1 < len(cmd.args):
+			after = cmd.args[i+1]
+			i++ // skip the value
+		case cmd.args[i] == "--sort" && i+1 < len(cmd.args):
+			switch cmd.args[i+1] {
+			case "published", "fetched", "feed":
+				params.Sort = cmd.args[i+1]
+			default:
+				return fmt.Errorf("invalid sort: %s (use published, fetched or feed)", cmd.args[i+1])
+			}
+			i++ // skip the value
+		case cmd.args[i] == "--feed" && i+1 < len(cmd.args):
+			params.Feeds = append(params.Feeds, cmd.args[i+1])
+			i++ // skip the value
+		case cmd.args[i] == "--since" && i+1 < len(cmd.args):
+			t, err := parseTimeBound(cmd.args[i+1], now)
+			if err != nil {
+				return err
+			}
+			params.Since = sql.NullTime{Time: t, Valid: true}
+			i++ // skip the value
+		case cmd.args[i] == "--until" && i+1 < len(cmd.args):
+			t, err := parseTimeBound(cmd.args[i+1], now)
+			if err != nil {
+				return err
+			}
+			params.Until = sql.NullTime{Time: t, Valid: true}
+			i++ // skip the value
+		case cmd.args[i] == "--unread":
+			params.UnreadOnly = true
+		case cmd.args[i] == "--all":
+			params.UnreadOnly = false
+		case cmd.args[i] == "--mark-read":
+			markRead = true
+		}
+	}
+
+	// The cursor is only meaningful for the sort it was made with, so it
+	// is decoded once the sort is known.
+	if after != "" {
+		cursor, err := parseBrowseCursor(after, params.Sort)
+		if err != nil {
+			return err
+		}
+		params.AfterID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+		params.AfterTime = sql.NullTime{Time: cursor.SortTime, Valid: true}
+		params.AfterFeed = sql.NullString{String: cursor.FeedName, Valid: true}
+	}
+
+	posts, err := s.db.GetPostsForUser(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to get posts: %v", err)
+	}
+
+	switch {
+	case s.output != outputText:
+		records := make([]postRecord, 0, len(posts))
+		for _, post := range posts {
+			records = append(records, postRecord{
+				ID:          post.ID,
+				Title:       post.Title,
+				URL:         post.Url,
+				FeedName:    post.FeedName,
+				Authors:     post.Authors.String,
+				PublishedAt: timePtr(post.PublishedAt),
+				FetchedAt:   post.CreatedAt.UTC(),
+				Read:        post.ReadAt.Valid,
+				Cursor:      newBrowseCursor(params.Sort, post).String(),
+			})
+		}
+		if err := writeRecords(os.Stdout, s.output, records); err != nil {
+			return err
+		}
+	case len(posts) == 0:
+		if params.UnreadOnly {
+			fmt.Println("No unread posts found.")
+		} else {
+			fmt.Println("No posts found.")
+		}
+		return nil
+	default:
+		for _, post := range posts {
+			published := "unknown"
+			if post.PublishedAt.Valid {
+				published = post.PublishedAt.Time.Format("2006-01-02 15:04")
+			}
+			status := "unread"
+			if post.ReadAt.Valid {
+				status = "read"
+			}
+			fmt.Printf("ID: %s\nTitle: %s\nURL: %s\nFeed: %s\n", post.ID, post.Title, post.Url, post.FeedName)
+			if post.Authors.Valid {
+				fmt.Printf("Authors: %s\n", post.Authors.String)
+			}
+			fmt.Printf("Published: %s\nStatus: %s\n\n", published, status)
+		}
+	}
+
+	if markRead {
+		for _, post := range posts {
+			err := s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+				UserID: user.ID,
+				PostID: post.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to mark post as read: %w", err)
+			}
+		}
+	}
+
+	// A full page means there may be more. Structured output carries a
+	// cursor on every post instead.
+	if s.output == outputText && len(posts) == int(params.Limit) {
+		cursor := newBrowseCursor(params.Sort, posts[len(posts)-1])
+		fmt.Printf("More posts: use --after %s\n", cursor)
+	}
+
+	return nil
+}
+
+func handlerRead(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: read <post_id|all>")
+	}
+
+	if cmd.args[0] == "all" {
+		n, err := s.db.MarkAllPostsRead(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("failed to mark posts as read: %w", err)
+		}
+		fmt.Printf("Marked %d posts as read.\n", n)
+		return nil
+	}
+
+	post, err := getPostByArg(ctx, s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	err = s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark post as read: %w", err)
+	}
+
+	fmt.Println("Marked as read:", post.Title)
+	return nil
+}
+
+func handlerUnread(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: unread <post_id>")
+	}
+
+	post, err := getPostByArg(ctx, s, cmd.args[0])
+	if err != nil {
+		return err
+	}
+	_, err = s.db.MarkPostUnread(ctx, database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark post as unread: %w", err)
+	}
+
+	fmt.Println("Marked as unread:", post.Title)
+	return nil
+}
+
+func handlerSave(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	var note sql.NullString
+	var positional []string
+
+	// parse --note flag
+	for i := 0; i < len(cmd.args); i++ {
+		if cmd.args[i] == "--note" && i+1 < len(cmd.args) {
+			note = sql.NullString{
+				String: cmd.args[i+1],
+				Valid:  true,
+			}
+			i++ // skip the value
+			continue
+		}
+		positional = append(positional, cmd.args[i])
+	}
+
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: save <post_id> [--note <text>]")
+	}
+	postID, err := uuid.Parse(positional[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %s", positional[0])
+	}
+
+	saved, err := s.db.CreateSavedPost(ctx, database.CreateSavedPostParams{
+		ID:     uuid.New(),
+		UserID: user.ID,
+		PostID: postID,
+		Note:   note,
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("post not found")
+		}
+		return fmt.Errorf("failed to save post: %w", err)
+	}
+
+	fmt.Println("Saved:", saved.Title)
+	return nil
+}
+
+func handlerUnsave(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: unsave <post_id>")
+	}
+	id, err := uuid.Parse(cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %s", cmd.args[0])
+	}
+
+	n, err := s.db.DeleteSavedPost(ctx, database.DeleteSavedPostParams{
+		UserID: user.ID,
+		ID:     id,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unsave post: %w", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post is not saved")
+	}
+
+	fmt.Println("Post removed from saved posts.")
+	return nil
+}
+
+func handlerSaved(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
 	limit := 2
 
 	// parse --limit flag
@@ -44,227 +321,1121 @@ func handlerBrowse(s *state, cmd command, user database.GetUserByNameRow) error
 		}
 	}
 
-	// Use the user passed from middleware
-	params := database.GetPostsForUserParams{
+	saved, err := s.db.GetSavedPostsForUser(ctx, database.GetSavedPostsForUserParams{
 		UserID: user.ID,
 		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get saved posts: %v", err)
 	}
 
-	posts, err := s.db.GetPostsForUser(context.Background(), params)
-	if err != nil {
-		return fmt.Errorf("failed to get posts: %v", err)
+	if s.output != outputText {
+		records := make([]savedPostRecord, 0, len(saved))
+		for _, post := range saved {
+			records = append(records, savedPostRecord{
+				ID:          savedPostID(post),
+				Title:       post.Title,
+				URL:         post.Url,
+				FeedName:    post.FeedName,
+				PublishedAt: timePtr(post.PublishedAt),
+				SavedAt:     post.CreatedAt.UTC(),
+				Note:        post.Note.String,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
 	}
 
-	if len(posts) == 0 {
-		fmt.Println("No posts found.")
+	if len(saved) == 0 {
+		fmt.Println("No saved posts found.")
 		return nil
 	}
 
-	for _, post := range posts {
-		published := "unknown"
-		if post.PublishedAt.Valid {
-			published = post.PublishedAt.Time.Format("2006-01-02 15:04")
+	for _, post := range saved {
+		id := savedPostID(post)
+		fmt.Printf("ID: %s\nTitle: %s\nURL: %s\nFeed: %s\nPublished: %s\n",
+			id, post.Title, post.Url, post.FeedName, formatNullTime(post.PublishedAt))
+		if post.Note.Valid {
+			fmt.Printf("Note: %s\n", post.Note.String)
 		}
-		fmt.Printf("Title: %s\nURL: %s\nPublished: %s\n\n",
-			post.Title, post.Url, published)
+		fmt.Println()
 	}
 
 	return nil
 }
 
-func scrapeFeeds(s *state) error {
-	ctx := context.Background()
+// savedPostID is the id a saved post is shown under. Posts whose feed
+// has been deleted are only known by the id of the saved copy.
+func savedPostID(post database.SavedPost) uuid.UUID {
+	if post.PostID.Valid {
+		return post.PostID.UUID
+	}
+	return post.ID
+}
+
+func handlerSearch(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	params := database.SearchPostsForUserParams{
+		UserID: user.ID,
+		Limit:  10,
+	}
+	var terms []string
+	now := time.Now()
 
-	feed, err := s.db.GetNextFeedToFetch(ctx)
+	// parse --feed, --since, --until and --limit flags
+	for i := 0; i < len(cmd.args); i++ {
+		switch {
+		case cmd.args[i] == "--feed" && i+1 < len(cmd.args):
+			params.Feed = sql.NullString{
+				String: cmd.args[i+1],
+				Valid:  true,
+			}
+			i++ // skip the value
+		case cmd.args[i] == "--since" && i+1 < len(cmd.args):
+			t, err := parseTimeBound(cmd.args[i+1], now)
+			if err != nil {
+				return err
+			}
+			params.Since = sql.NullTime{Time: t, Valid: true}
+			i++ // skip the value
+		case cmd.args[i] == "--until" && i+1 < len(cmd.args):
+			t, err := parseTimeBound(cmd.args[i+1], now)
+			if err != nil {
+				return err
+			}
+			params.Until = sql.NullTime{Time: t, Valid: true}
+			i++ // skip the value
+		case cmd.args[i] == "--limit" && i+1 < len(cmd.args):
+			l, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil {
+				return fmt.Errorf("invalid limit: %v", err)
+			}
+			params.Limit = int32(l)
+			i++ // skip the value
+		default:
+			terms = append(terms, cmd.args[i])
+		}
+	}
+
+	if len(terms) == 0 {
+		return fmt.Errorf("usage: search <query> [--feed <name|url>] [--since <time>] [--until <time>] [--limit N]")
+	}
+	// Unquoted multi-word queries arrive as separate arguments.
+	params.Query = strings.Join(terms, " ")
+
+	results, err := s.db.SearchPostsForUser(ctx, params)
+	if err != nil {
+		return fmt.Errorf("failed to search posts: %v", err)
+	}
+
+	if s.output != outputText {
+		records := make([]searchResultRecord, 0, len(results))
+		for _, post := range results {
+			records = append(records, searchResultRecord{
+				ID:          post.ID,
+				Title:       post.Title,
+				URL:         post.Url,
+				FeedName:    post.FeedName,
+				PublishedAt: timePtr(post.PublishedAt),
+				Rank:        post.Rank,
+				Snippet:     strings.Join(strings.Fields(post.Snippet), " "),
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No matching posts found.")
+		return nil
+	}
+
+	for _, post := range results {
+		fmt.Printf("ID: %s\nTitle: %s\nURL: %s\nFeed: %s\nPublished: %s\nRank: %.3f\n",
+			post.ID, post.Title, post.Url, post.FeedName, formatNullTime(post.PublishedAt), post.Rank)
+		fmt.Printf("  %s\n\n", strings.Join(strings.Fields(post.Snippet), " "))
+	}
+
+	return nil
+}
+
+// getPostByArg looks up a post from a post id given on the command line.
+func getPostByArg(ctx context.Context, s *state, arg string) (database.Post, error) {
+	id, err := uuid.Parse(arg)
+	if err != nil {
+		return database.Post{}, fmt.Errorf("invalid post id: %s", arg)
+	}
+	post, err := s.db.GetPost(ctx, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return database.Post{}, fmt.Errorf("post not found")
+		}
+		return database.Post{}, fmt.Errorf("failed to get post: %w", err)
+	}
+	return post, nil
+}
+
+// handlerPost dispatches the "post <subcommand>" family of commands.
+func handlerPost(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: post <history> ...")
+	}
+	sub := command{
+		name: cmd.name + " " + cmd.args[0],
+		args: cmd.args[1:],
+	}
+	switch cmd.args[0] {
+	case "history":
+		return handlerPostHistory(ctx, s, sub)
+	default:
+		return fmt.Errorf("unknown post command: %s", cmd.args[0])
+	}
+}
+
+func handlerPostHistory(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: post history <post_id>")
+	}
+	post, err := getPostByArg(ctx, s, cmd.args[0])
 	if err != nil {
 		return err
 	}
-	err = s.db.MarkFeedFetched(ctx, feed.ID)
+	revisions, err := s.db.GetPostRevisions(ctx, post.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get post history: %w", err)
+	}
+
+	fmt.Printf("Title: %s\nURL: %s\n\n", post.Title, post.Url)
+	if len(revisions) == 0 {
+		fmt.Println("No recorded changes.")
+		return nil
+	}
+
+	// Each revision is the version that was replaced at its created_at, so
+	// compare it with the one that came after it.
+	for i, rev := range revisions {
+		next := postVersion{post.Title, post.Description, post.PublishedAt}
+		if i+1 < len(revisions) {
+			r := revisions[i+1]
+			next = postVersion{r.Title, r.Description, r.PublishedAt}
+		}
+		prev := postVersion{rev.Title, rev.Description, rev.PublishedAt}
+
+		fmt.Printf("Changed at %s:\n", rev.CreatedAt.Format("2006-01-02 15:04"))
+		if prev.title != next.title {
+			fmt.Printf("  Title: %q -> %q\n", prev.title, next.title)
+		}
+		if prev.description != next.description {
+			fmt.Printf("  Description: %q -> %q\n",
+				truncate(prev.description.String, 80), truncate(next.description.String, 80))
+		}
+		if prev.publishedAt.Valid != next.publishedAt.Valid ||
+			!prev.publishedAt.Time.Equal(next.publishedAt.Time) {
+			fmt.Printf("  Published: %s -> %s\n",
+				formatNullTime(prev.publishedAt), formatNullTime(next.publishedAt))
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// postVersion holds the fields of a post that revisions track.
+type postVersion struct {
+	title       string
+	description sql.NullString
+	publishedAt sql.NullTime
+}
+
+func formatNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return "unknown"
+	}
+	return t.Time.Format("2006-01-02 15:04")
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// aggOptions configures how handlerAgg fetches feeds.
+type aggOptions struct {
+	interval  time.Duration
+	workers   int
+	batchSize int
+	// lease is how long a claimed feed stays reserved for this process.
+	// It should comfortably exceed the time a single fetch can take.
+	lease time.Duration
+	// maxFailures is the number of consecutive failed fetches after which
+	// a feed is disabled; 0 keeps retrying forever.
+	maxFailures int
+	// drainTimeout is how long in-flight fetches may keep running after
+	// a shutdown has been requested.
+	drainTimeout time.Duration
+	// keepHistory saves the previous version of posts that change.
+	keepHistory bool
+}
+
+// aggStats accumulates what handlerAgg did over its lifetime. It is
+// updated concurrently by the scrape workers.
+type aggStats struct {
+	feedsFetched  atomic.Int64
+	feedsFailed   atomic.Int64
+	postsInserted atomic.Int64
+	// feedsSkipped counts feeds that came due again during an agg --once
+	// run after it had already fetched them.
+	feedsSkipped atomic.Int64
+}
+
+// record counts the outcome of one scrapeFeed call and reports failures.
+func (st *aggStats) record(feed database.ClaimFeedsToFetchRow, report *fetchReport, err error) {
+	st.postsInserted.Add(int64(report.inserted))
+	if err != nil {
+		st.feedsFailed.Add(1)
+		fmt.Printf("scrape error for feed %s: %v\n", feed.Name, err)
+		return
+	}
+	st.feedsFetched.Add(1)
+}
+
+func (st *aggStats) String() string {
+	summary := fmt.Sprintf("%d feeds fetched, %d posts inserted, %d failures",
+		st.feedsFetched.Load(), st.postsInserted.Load(), st.feedsFailed.Load())
+	if skipped := st.feedsSkipped.Load(); skipped > 0 {
+		summary += fmt.Sprintf(", %d skipped as already fetched", skipped)
+	}
+	return summary
+}
+
+// claimDueFeeds leases up to opts.batchSize feeds that are due.
+func claimDueFeeds(ctx context.Context, s *state, opts aggOptions) ([]database.ClaimFeedsToFetchRow, error) {
+	return s.db.ClaimFeedsToFetch(ctx, database.ClaimFeedsToFetchParams{
+		LeaseSeconds: int32(opts.lease / time.Second),
+		BatchSize:    int32(opts.batchSize),
+	})
+}
+
+// scrapeFeeds claims a batch of due feeds and fetches them.
+func scrapeFeeds(ctx context.Context, s *state, opts aggOptions, stats *aggStats) error {
+	feeds, err := claimDueFeeds(ctx, s, opts)
 	if err != nil {
 		return err
 	}
-	rss, err := fetchFeed(ctx, feed.Url)
+	scrapeClaimedFeeds(ctx, s, opts, stats, feeds)
+	return nil
+}
+
+// scrapeClaimedFeeds fetches feeds claimed by this process with at most
+// opts.workers requests in flight. A failing feed is reported on its own
+// and doesn't stop the rest of the batch.
+//
+// Once ctx is cancelled no further feeds are started, and fetches already
+// in flight get opts.drainTimeout to finish before they are cancelled too.
+// Only the requests are cancelled: what follows a fetch, including
+// handing back its claim, still runs on a bookkeepingContext.
+func scrapeClaimedFeeds(ctx context.Context, s *state, opts aggOptions, stats *aggStats, feeds []database.ClaimFeedsToFetchRow) {
+	workCtx, cancelWork := drainContext(ctx, opts.drainTimeout)
+	defer cancelWork()
+
+	jobs := make(chan database.ClaimFeedsToFetchRow)
+	var wg sync.WaitGroup
+	for i := 0; i < opts.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				report, err := scrapeFeed(workCtx, s, feed, opts)
+				stats.record(feed, report, err)
+			}
+		}()
+	}
+
+	dispatched := 0
+dispatch:
+	for _, feed := range feeds {
+		select {
+		case jobs <- feed:
+			dispatched++
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	// Hand back feeds we claimed but never started, rather than leaving
+	// them leased to a process that is shutting down.
+	for _, feed := range feeds[dispatched:] {
+		releaseFeedClaim(ctx, s, feed)
+	}
+}
+
+// drainContext returns a context that is cancelled grace after ctx is,
+// giving work in progress a deadline to finish once shutdown is requested.
+func drainContext(ctx context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	drainCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(ctx, func() {
+		time.AfterFunc(grace, cancel)
+	})
+	return drainCtx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// bookkeepingTimeout bounds the database work that follows a fetch.
+const bookkeepingTimeout = 30 * time.Second
+
+// bookkeepingContext returns a context for the database work that follows
+// a fetch. It is not cancelled with ctx, so a fetch that was cut short by
+// a shutdown is still recorded and its claim handed back, but it gives up
+// after bookkeepingTimeout.
+func bookkeepingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), bookkeepingTimeout)
+}
+
+// releaseFeedClaim hands a claimed feed back. A failure is only logged,
+// since the claim expires with its lease anyway.
+func releaseFeedClaim(ctx context.Context, s *state, feed database.ClaimFeedsToFetchRow) {
+	ctx, cancel := bookkeepingContext(ctx)
+	defer cancel()
+	if err := s.db.ReleaseFeedClaim(ctx, feed.ID); err != nil {
+		log.Printf("error releasing claim on feed %s: %v", feed.Name, err)
+	}
+}
+
+// scrapeFeed fetches a single claimed feed and saves its posts. The
+// returned report, which is also logged and stored, says what the fetch did.
+// Cancelling ctx cancels the request; the rest runs to completion.
+func scrapeFeed(ctx context.Context, s *state, feed database.ClaimFeedsToFetchRow, opts aggOptions) (*fetchReport, error) {
+	defer releaseFeedClaim(ctx, s, feed)
+
+	report := &fetchReport{
+		feedName:  feed.Name,
+		startedAt: time.Now(),
+	}
+	err := fetchAndSaveFeed(ctx, s, feed, opts, report)
+	report.finishedAt = time.Now()
+
+	dbCtx, cancel := bookkeepingContext(ctx)
+	defer cancel()
+	if err != nil {
+		report.status = fetchStatusFailed
+		report.err = err
+		err = recordFeedFailure(dbCtx, s, feed, opts, err)
+	}
+	saveFetchReport(dbCtx, s, feed.ID, report)
+
+	return report, err
+}
+
+func fetchAndSaveFeed(
+	ctx context.Context,
+	s *state,
+	feed database.ClaimFeedsToFetchRow,
+	opts aggOptions,
+	report *fetchReport,
+) error {
+	result, err := fetchFeed(ctx, feed.Url, feedCache{
+		ETag:         feed.Etag.String,
+		LastModified: feed.LastModified.String,
+	})
 	if err != nil {
 		return err
 	}
-	for _, item := range rss.Channel.Item {
-		err := savePost(ctx, s, feed, item)
+
+	// The response is in hand, so saving it shouldn't be cut short.
+	ctx, cancel := bookkeepingContext(ctx)
+	defer cancel()
+	if result.MovedTo != "" {
+		moveFeed(ctx, s, feed, result.MovedTo)
+	}
+	// A 304 carries no body, so keep the hint from the last full fetch.
+	hint := time.Duration(feed.HintedIntervalSeconds.Int32) * time.Second
+	if result.NotModified {
+		report.status = fetchStatusNotModified
+	} else {
+		report.status = fetchStatusOK
+		hint = result.Feed.UpdateInterval
+		for _, item := range result.Feed.Items {
+			report.add(savePost(ctx, s, feed, item, opts))
+		}
+	}
+	return s.db.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+		ID:                 feed.ID,
+		NextFetchInSeconds: int32(feedFetchInterval(ctx, s, feed, hint) / time.Second),
+		HintedIntervalSeconds: sql.NullInt32{
+			Int32: int32(hint / time.Second),
+			Valid: hint > 0,
+		},
+		Etag: sql.NullString{
+			String: result.Cache.ETag,
+			Valid:  result.Cache.ETag != "",
+		},
+		LastModified: sql.NullString{
+			String: result.Cache.LastModified,
+			Valid:  result.Cache.LastModified != "",
+		},
+	})
+}
+
+// moveFeed points a feed that redirected permanently at its new URL. A
+// failed move is only logged, as the feed can still be fetched through
+// the redirect.
+func moveFeed(ctx context.Context, s *state, feed database.ClaimFeedsToFetchRow, newURL string) {
+	n, err := s.db.MoveFeedURL(ctx, database.MoveFeedURLParams{
+		ID:     feed.ID,
+		OldUrl: feed.Url,
+		NewUrl: newURL,
+	})
+	if err != nil {
+		log.Printf("error moving feed %s to %s: %v", feed.Name, newURL, err)
+		return
+	}
+	if n == 0 {
+		log.Printf("feed %s redirects to %s, which is already another feed", feed.Name, newURL)
+		return
+	}
+	log.Printf("feed %s moved permanently from %s to %s", feed.Name, feed.Url, newURL)
+}
+
+// recordFeedFailure stores a failed fetch on the feed, which backs off its
+// next attempt and disables it after opts.maxFailures failures in a row,
+// or right away if the feed is gone. It returns fetchErr, annotated if the
+// feed has just been disabled.
+func recordFeedFailure(
+	ctx context.Context,
+	s *state,
+	feed database.ClaimFeedsToFetchRow,
+	opts aggOptions,
+	fetchErr error,
+) error {
+	hint := time.Duration(feed.HintedIntervalSeconds.Int32) * time.Second
+	retry := retryInterval(feedFetchInterval(ctx, s, feed, hint), int(feed.ConsecutiveFailures)+1)
+
+	maxFailures := opts.maxFailures
+	reason := fmt.Sprintf("failed %d times in a row", maxFailures)
+	if errors.Is(fetchErr, errFeedGone) {
+		maxFailures = 1
+		reason = fetchErr.Error()
+	}
+
+	failure, err := s.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:                 feed.ID,
+		LastError:          fetchErr.Error(),
+		NextFetchInSeconds: int32(retry / time.Second),
+		MaxFailures:        int32(maxFailures),
+		DisabledReason:     reason,
+	})
+	if err != nil {
+		log.Printf("error recording failure for feed %s: %v", feed.Name, err)
+		return fetchErr
+	}
+	if failure.DisabledAt.Valid {
+		if errors.Is(fetchErr, errFeedGone) {
+			return fmt.Errorf("%w (feed disabled)", fetchErr)
+		}
+		return fmt.Errorf("%w (feed disabled after %d consecutive failures)", fetchErr, failure.ConsecutiveFailures)
+	}
+	return fetchErr
+}
+
+// feedFetchInterval works out how long to wait until the feed's next
+// regular fetch from its configured interval, the publisher's hint and
+// how often it has been posting.
+func feedFetchInterval(
+	ctx context.Context,
+	s *state,
+	feed database.ClaimFeedsToFetchRow,
+	hint time.Duration,
+) time.Duration {
+	override := time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second
+
+	averageGap, err := s.db.GetFeedPostingStats(ctx, feed.ID)
+	if err != nil {
+		log.Printf("error getting posting stats for feed %s: %v", feed.Name, err)
+	}
+
+	return fetchInterval(override, hint, time.Duration(averageGap)*time.Second)
+}
+
+// savePost stores a feed item as a post, updating the stored post if the
+// publisher has changed it since, and reports which of the two it did. An
+// item that is already stored unchanged is a postDuplicate; the error is
+// only set for postFailed. With opts.keepHistory, the version being
+// replaced is kept as a revision.
+func savePost(
+	ctx context.Context,
+	s *state,
+	feed database.ClaimFeedsToFetchRow,
+	item FeedItem,
+	opts aggOptions,
+) (postOutcome, error) {
+	publishedAt := parsePubDate(item.PubDate)
+
+	description := sql.NullString{
+		String: item.Description,
+		Valid:  item.Description != "",
+	}
+	authors := sql.NullString{
+		String: strings.Join(item.Authors, ", "),
+		Valid:  len(item.Authors) > 0,
+	}
+
+	guid := fallbackGUID(item)
+	if item.ID != "" {
+		err := s.db.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{
+			FeedID:       feed.ID,
+			Guid:         item.ID,
+			FallbackGuid: guid,
+		})
 		if err != nil {
-			log.Printf("error saving post from feed %s: %v", feed.Name, err)
+			return postFailed, fmt.Errorf("failed to save post %q: %w", item.Title, err)
+		}
+		guid = item.ID
+	}
+
+	contentHash := postContentHash(item.Title, description, publishedAt)
+	var result database.UpsertPostRow
+	save := func(store storage.Store) error {
+		if opts.keepHistory {
+			err := store.CreatePostRevision(ctx, database.CreatePostRevisionParams{
+				ID:          uuid.New(),
+				FeedID:      feed.ID,
+				Guid:        guid,
+				ContentHash: contentHash,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to save revision of post %q: %w", item.Title, err)
+			}
+		}
+
+		var err error
+		result, err = store.UpsertPost(ctx, database.UpsertPostParams{
+			ID:          uuid.New(),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+			Title:       item.Title,
+			Url:         item.Link,
+			Description: description,
+			PublishedAt: publishedAt,
+			FeedID:      feed.ID,
+			Guid:        guid,
+			ContentHash: sql.NullString{
+				String: contentHash,
+				Valid:  true,
+			},
+			Authors: authors,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to save post %q: %w", item.Title, err)
+		}
+		return nil
+	}
+
+	// A revision must not outlive a failed update, or the retry on the
+	// next fetch would keep the same version a second time.
+	var err error
+	if opts.keepHistory {
+		err = s.conn.InTx(ctx, save)
+	} else {
+		err = save(s.db)
+	}
+	if err != nil {
+		return postFailed, err
+	}
+
+	switch {
+	case result.Inserted > 0:
+		return postInserted, nil
+	case result.Updated > 0:
+		return postUpdated, nil
+	default:
+		return postDuplicate, nil
+	}
+}
+
+// postContentHash fingerprints the parts of a post a publisher may edit,
+// so UpsertPost can tell a changed post from one it has already stored.
+func postContentHash(title string, description sql.NullString, publishedAt sql.NullTime) string {
+	published := ""
+	if publishedAt.Valid {
+		published = publishedAt.Time.UTC().Format(time.RFC3339)
+	}
+	sum := sha256.Sum256([]byte(title + "\x00" + description.String + "\x00" + published))
+	return hex.EncodeToString(sum[:])
+}
+
+// fallbackGUID identifies items whose feed doesn't give them an ID. It
+// must match the backfill in sql/schema/010_add_guid_to_posts.sql, which
+// hashed the URL posts were stored with back then: the raw link.
+func fallbackGUID(item FeedItem) string {
+	sum := sha256.Sum256([]byte(item.RawLink + "\n" + item.Title))
+	return hex.EncodeToString(sum[:])
+}
+
+func parsePubDate(pubDate string) sql.NullTime {
+	if pubDate == "" {
+		return sql.NullTime{Valid: false}
+	}
+
+	layouts := []string{
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC822Z,
+		time.RFC822,
+		time.RFC3339Nano,
+		time.RFC3339,
+		// dc:date is W3C-DTF, which also allows reduced precision.
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02",
+	}
+
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, pubDate); err == nil {
+			return sql.NullTime{
+				Time:  t,
+				Valid: true,
+			}
+		}
+	}
+
+	return sql.NullTime{Valid: false}
+}
+
+func handlerUnfollow(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: Unfollow <feeed_url>")
+	}
+
+	feed, err := s.db.GetFeedByURL(ctx, cmd.args[0])
+	if err != nil {
+		return fmt.Errorf("feed not found")
+	}
+	err = s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Unfollowed:", feed.Name)
+	return nil
+}
+
+func handlerFollowing(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if s.output != outputText {
+		records := make([]followRecord, 0, len(follows))
+		for _, follow := range follows {
+			records = append(records, followRecord{
+				ID:          follow.ID,
+				FeedID:      follow.FeedID,
+				FeedName:    follow.FeedName,
+				FeedURL:     follow.FeedUrl,
+				FollowedAt:  follow.CreatedAt.UTC(),
+				UnreadCount: follow.UnreadCount,
+			})
 		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	if len(follows) == 0 {
+		fmt.Println("You are not following any feeds.")
+		return nil
+	}
+
+	fmt.Println("You are following:")
+	for _, follow := range follows {
+		fmt.Printf("- %s (%d unread)\n", follow.FeedName, follow.UnreadCount)
+	}
+
+	return nil
+}
+
+func handlerFollow(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: follow <feed_url>")
+	}
+	feedURL := cmd.args[0]
+
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if err != nil {
+		return fmt.Errorf("feed not found for url %s", feedURL)
+	}
+
+	feedFollow, err := s.db.CreateFeedFollow(
+		ctx,
+		database.CreateFeedFollowParams{
+			ID:        uuid.New(),
+			CreatedAt: time.Now().UTC(),
+			UpdatedAt: time.Now().UTC(),
+			UserID:    user.ID,
+			FeedID:    feed.ID,
+		})
+	if err != nil {
+		return err
+	}
+	fmt.Println("Feed follow created:")
+	fmt.Println("ID:", feedFollow.ID)
+	fmt.Println("User:", feedFollow.UserName)
+	fmt.Println("Feed:", feedFollow.FeedName)
+
+	return nil
+}
+
+func handlerFeeds(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) == 1 && cmd.args[0] == "--errors" {
+		return handlerFeedErrors(ctx, s)
+	}
+	if len(cmd.args) != 0 {
+		return fmt.Errorf("usage: feeds [--errors]")
+	}
+	feeds, err := s.db.GetFeeds(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	if s.output != outputText {
+		records := make([]feedRecord, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, feedRecord{
+				ID:            feed.FeedID,
+				Name:          feed.FeedName,
+				URL:           feed.FeedUrl,
+				CreatedBy:     feed.UserName,
+				CreatedAt:     feed.CreatedAt.UTC(),
+				LastFetchedAt: timePtr(feed.LastFetchedAt),
+				NextFetchAt:   timePtr(feed.NextFetchAt),
+				DisabledAt:    timePtr(feed.DisabledAt),
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+	for _, feed := range feeds {
+		fmt.Println("Feed:")
+		fmt.Printf("  Name: %s\n", feed.FeedName)
+		fmt.Printf("  URL: %s\n", feed.FeedUrl)
+		fmt.Printf("  Created by: %s\n", feed.UserName)
+		fmt.Println()
+	}
+	return nil
+}
+
+func handlerFeedErrors(ctx context.Context, s *state) error {
+	feeds, err := s.db.GetFeedsWithErrors(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get feeds: %w", err)
+	}
+	if s.output != outputText {
+		records := make([]feedErrorRecord, 0, len(feeds))
+		for _, feed := range feeds {
+			records = append(records, feedErrorRecord{
+				ID:                  feed.FeedID,
+				Name:                feed.FeedName,
+				URL:                 feed.FeedUrl,
+				CreatedBy:           feed.UserName,
+				ConsecutiveFailures: feed.ConsecutiveFailures,
+				LastError:           feed.LastError.String,
+				LastErrorAt:         timePtr(feed.LastErrorAt),
+				DisabledAt:          timePtr(feed.DisabledAt),
+				DisabledReason:      feed.DisabledReason.String,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+	if len(feeds) == 0 {
+		fmt.Println("No failing feeds.")
+		return nil
+	}
+	for _, feed := range feeds {
+		fmt.Println("Feed:")
+		fmt.Printf("  Name: %s\n", feed.FeedName)
+		fmt.Printf("  URL: %s\n", feed.FeedUrl)
+		fmt.Printf("  Created by: %s\n", feed.UserName)
+		fmt.Printf("  Consecutive failures: %d\n", feed.ConsecutiveFailures)
+		if feed.LastErrorAt.Valid {
+			fmt.Printf("  Last error (%s): %s\n",
+				feed.LastErrorAt.Time.Format("2006-01-02 15:04"), feed.LastError.String)
+		}
+		if feed.DisabledAt.Valid {
+			fmt.Printf("  Disabled since: %s (%s)\n",
+				feed.DisabledAt.Time.Format("2006-01-02 15:04"), feed.DisabledReason.String)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// handlerFeed dispatches the "feed <subcommand>" family of commands.
+func handlerFeed(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: feed <enable|set-interval|rm|rename|set-url> ...")
+	}
+	sub := command{
+		name: cmd.name + " " + cmd.args[0],
+		args: cmd.args[1:],
+	}
+	switch cmd.args[0] {
+	case "enable":
+		return handlerFeedEnable(ctx, s, sub, user)
+	case "set-interval":
+		return handlerFeedSetInterval(ctx, s, sub, user)
+	case "rm":
+		return handlerFeedRemove(ctx, s, sub, user)
+	case "rename":
+		return handlerFeedRename(ctx, s, sub, user)
+	case "set-url":
+		return handlerFeedSetURL(ctx, s, sub, user)
+	default:
+		return fmt.Errorf("unknown feed command: %s", cmd.args[0])
+	}
+}
+
+func handlerFeedEnable(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: feed enable <feed_url>")
+	}
+	feed, err := getOwnedFeed(ctx, s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	if err := s.db.EnableFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("failed to enable feed: %w", err)
+	}
+
+	fmt.Println("Feed enabled:", feed.Name)
+	return nil
+}
+
+func handlerFeedSetInterval(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("usage: feed set-interval <feed_url> <duration|auto>")
+	}
+	interval, err := parseFetchInterval(cmd.args[1])
+	if err != nil {
+		return err
+	}
+	feed, err := getOwnedFeed(ctx, s, cmd.args[0], user)
+	if err != nil {
+		return err
+	}
+	err = s.db.SetFeedInterval(ctx, database.SetFeedIntervalParams{
+		ID:                   feed.ID,
+		FetchIntervalSeconds: interval,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set interval: %w", err)
+	}
+
+	if interval.Valid {
+		fmt.Printf("Feed %s will be fetched every %s\n", feed.Name, cmd.args[1])
+	} else {
+		fmt.Printf("Feed %s will be fetched on an adaptive schedule\n", feed.Name)
 	}
 	return nil
 }
 
-func savePost(
-	ctx context.Context,
-	s *state,
-	feed database.GetNextFeedToFetchRow,
-	item RSSItem,
-) error {
-	publishedAt := parsePubDate(item.PubDate)
+func handlerFeedRemove(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	force := false
+	var positional []string
 
-	description := sql.NullString{
-		String: item.Description,
-		Valid:  item.Description != "",
+	// parse --force flag
+	for _, arg := range cmd.args {
+		if arg == "--force" {
+			force = true
+			continue
+		}
+		positional = append(positional, arg)
 	}
 
-	_, err := s.db.CreatePost(ctx, database.CreatePostParams{
-		ID:          uuid.New(),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		Title:       item.Title,
-		Url:         item.Link,
-		Description: description,
-		PublishedAt: publishedAt,
-		FeedID:      feed.ID,
-	})
-
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: feed rm <feed_url> [--force]")
+	}
+	feed, err := getOwnedFeed(ctx, s, positional[0], user)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil //duplicate post
-		}
+		return err
 	}
-	return err
-}
 
-func parsePubDate(pubDate string) sql.NullTime {
-	if pubDate == "" {
-		return sql.NullTime{Valid: false}
+	if !force {
+		counts, err := s.db.CountFeedDependents(ctx, feed.ID)
+		if err != nil {
+			return fmt.Errorf("failed to count posts and follows: %w", err)
+		}
+		ok, err := confirm(fmt.Sprintf("Delete feed %s with %d posts and %d follows?",
+			feed.Name, counts.PostCount, counts.FollowCount))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Println("Feed not deleted.")
+			return nil
+		}
 	}
 
-	layouts := []string{
-		time.RFC1123Z,
-		time.RFC1123,
-		time.RFC822Z,
-		time.RFC822,
+	n, err := s.db.DeleteFeed(ctx, database.DeleteFeedParams{
+		ID:     feed.ID,
+		UserID: user.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete feed: %w", err)
 	}
-
-	for _, layout := range layouts {
-		if t, err := time.Parse(layout, pubDate); err == nil {
-			return sql.NullTime{
-				Time:  t,
-				Valid: true,
-			}
-		}
+	if n == 0 {
+		return fmt.Errorf("feed not found for url %s", feed.Url)
 	}
 
-	return sql.NullTime{Valid: false}
+	fmt.Println("Feed deleted:", feed.Name)
+	return nil
 }
 
-func handlerUnfollow(s *state, cmd command, user database.GetUserByNameRow) error {
-	if len(cmd.args) < 1 {
-		return fmt.Errorf("usage: Unfollow <feeed_url>")
+func handlerFeedRename(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("usage: feed rename <feed_url> <name>")
 	}
-
-	ctx := context.Background()
-
-	feed, err := s.db.GetFeedByURL(ctx, cmd.args[0])
+	feed, err := getOwnedFeed(ctx, s, cmd.args[0], user)
 	if err != nil {
-		return fmt.Errorf("feed not found")
+		return err
 	}
-	err = s.db.DeleteFeedFollow(ctx, database.DeleteFeedFollowParams{
-		UserID: user.ID,
-		FeedID: feed.ID,
+	err = s.db.RenameFeed(ctx, database.RenameFeedParams{
+		ID:   feed.ID,
+		Name: cmd.args[1],
 	})
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to rename feed: %w", err)
 	}
 
-	fmt.Println("Unfollowed:", feed.Name)
+	fmt.Printf("Feed %s renamed to %s\n", feed.Name, cmd.args[1])
 	return nil
 }
 
-func handlerFollowing(s *state, cmd command, user database.GetUserByNameRow) error {
-	ctx := context.Background()
-
-	follows, err := s.db.GetFeedFollowsForUser(ctx, user.ID)
+func handlerFeedSetURL(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("usage: feed set-url <old_url> <new_url>")
+	}
+	feed, err := getOwnedFeed(ctx, s, cmd.args[0], user)
 	if err != nil {
 		return err
 	}
-
-	if len(follows) == 0 {
-		fmt.Println("You are not following any feeds.")
-		return nil
+	if existing, err := s.db.GetFeedByURL(ctx, cmd.args[1]); err == nil && existing.ID != feed.ID {
+		return fmt.Errorf("a feed already exists for url %s", cmd.args[1])
 	}
-
-	fmt.Println("You are following:")
-	for _, follow := range follows {
-		fmt.Println("-", follow.FeedName)
+	err = s.db.SetFeedURL(ctx, database.SetFeedURLParams{
+		ID:  feed.ID,
+		Url: cmd.args[1],
+	})
+	if err != nil {
+		return fmt.Errorf("failed to change feed url: %w", err)
 	}
 
+	fmt.Printf("Feed %s now fetches %s\n", feed.Name, cmd.args[1])
 	return nil
 }
 
-func handlerFollow(s *state, cmd command, user database.GetUserByNameRow) error {
-	if len(cmd.args) < 1 {
-		return fmt.Errorf("usage: follow <feed_url>")
-	}
-	ctx := context.Background()
-	feedURL := cmd.args[0]
-
+// getOwnedFeed looks up a feed by URL for a command that only the user
+// who added the feed may run.
+func getOwnedFeed(ctx context.Context, s *state, feedURL string, user database.GetUserByNameRow) (database.GetFeedByURLRow, error) {
 	feed, err := s.db.GetFeedByURL(ctx, feedURL)
 	if err != nil {
-		return fmt.Errorf("feed not found for url %s", feedURL)
+		return database.GetFeedByURLRow{}, fmt.Errorf("feed not found for url %s", feedURL)
 	}
-
-	feedFollow, err := s.db.CreateFeedFollow(
-		ctx,
-		database.CreateFeedFollowParams{
-			ID:        uuid.New(),
-			CreatedAt: time.Now().UTC(),
-			UpdatedAt: time.Now().UTC(),
-			UserID:    user.ID,
-			FeedID:    feed.ID,
-		})
-	if err != nil {
-		return err
+	if feed.UserID != user.ID {
+		return database.GetFeedByURLRow{}, fmt.Errorf("feed %s was added by another user", feed.Name)
 	}
-	fmt.Println("Feed follow created:")
-	fmt.Println("ID:", feedFollow.ID)
-	fmt.Println("User:", feedFollow.UserName)
-	fmt.Println("Feed:", feedFollow.FeedName)
+	return feed, nil
+}
 
-	return nil
+// stdin is shared by all prompts so that answers piped in together are
+// not lost to another reader's buffer.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on stdin. Anything but y or yes,
+// including end of input, counts as no.
+func confirm(question string) (bool, error) {
+	fmt.Printf("%s [y/N] ", question)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read answer: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
 }
 
-func handlerFeeds(s *state, cmd command) error {
-	if len(cmd.args) != 0 {
-		return fmt.Errorf("Feeds does not take any arguments")
+// prompt asks for a value on stdin. An empty answer, or end of input,
+// keeps def, which is displayed as shown.
+func prompt(question, def, shown string) (string, error) {
+	fmt.Printf("%s [%s]: ", question, shown)
+	answer, err := stdin.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read answer: %w", err)
+	}
+	if answer = strings.TrimSpace(answer); answer != "" {
+		return answer, nil
 	}
-	ctx := context.Background()
+	return def, nil
+}
 
-	feeds, err := s.db.GetFeeds(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to get feeds: %w", err)
+// parseFetchInterval parses a per-feed polling interval. "auto" clears
+// the override so the feed is scheduled adaptively.
+func parseFetchInterval(value string) (sql.NullInt32, error) {
+	if value == "auto" {
+		return sql.NullInt32{}, nil
 	}
-	for _, feed := range feeds {
-		fmt.Println("Feed:")
-		fmt.Printf("  Name: %s\n", feed.FeedName)
-		fmt.Printf("  URL: %s\n", feed.FeedUrl)
-		fmt.Printf("  Created by: %s\n", feed.UserName)
-		fmt.Println()
+	d, err := time.ParseDuration(value)
+	if err != nil || d < minFetchInterval {
+		return sql.NullInt32{}, fmt.Errorf("invalid interval: %s (must be at least %s)", value, minFetchInterval)
 	}
-	return nil
+	return sql.NullInt32{
+		Int32: int32(d / time.Second),
+		Valid: true,
+	}, nil
 }
 
-func handlerAddFeed(s *state, cmd command, user database.GetUserByNameRow) error {
-	if len(cmd.args) != 2 {
-		return fmt.Errorf("usage: addfeed <name> <url>")
+func handlerAddFeed(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error {
+	var interval sql.NullInt32
+	var positional []string
+
+	// parse --interval flag
+	for i := 0; i < len(cmd.args); i++ {
+		if cmd.args[i] == "--interval" && i+1 < len(cmd.args) {
+			parsed, err := parseFetchInterval(cmd.args[i+1])
+			if err != nil {
+				return err
+			}
+			interval = parsed
+			i++ // skip the value
+			continue
+		}
+		positional = append(positional, cmd.args[i])
 	}
 
-	name := cmd.args[0]
-	url := cmd.args[1]
+	if len(positional) != 2 {
+		return fmt.Errorf("usage: addfeed <name> <url> [--interval DURATION]")
+	}
+
+	name := positional[0]
+	url := positional[1]
 
-	ctx := context.Background()
+	// The URL may belong to a feed that has since moved.
+	if existing, err := s.db.GetFeedByURL(ctx, url); err == nil {
+		return fmt.Errorf("feed %s already exists at %s", existing.Name, existing.Url)
+	}
 
 	feed, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
-		ID:        uuid.New(),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Name:      name,
-		Url:       url,
-		UserID:    user.ID,
+		ID:                   uuid.New(),
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+		Name:                 name,
+		Url:                  url,
+		UserID:               user.ID,
+		FetchIntervalSeconds: interval,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create feed: %w", err)
@@ -289,37 +1460,228 @@ func handlerAddFeed(s *state, cmd command, user database.GetUserByNameRow) error
 	return nil
 }
 
-func handlerAgg(s *state, cmd command) error {
-	if len(cmd.args) != 1 {
-		return fmt.Errorf("usage: agg <time_between_reqs>")
+func handlerAgg(ctx context.Context, s *state, cmd command) error {
+	opts := aggOptions{
+		workers:      4,
+		batchSize:    10,
+		lease:        5 * time.Minute,
+		maxFailures:  10,
+		drainTimeout: 30 * time.Second,
+	}
+	once := false
+	feedURL := ""
+	var maxDuration time.Duration
+	var positional []string
+
+	// parse --workers, --batch, --lease, --max-failures, --drain-timeout,
+	// --once, --feed, --max-duration and --keep-history flags
+	for i := 0; i < len(cmd.args); i++ {
+		switch {
+		case cmd.args[i] == "--workers" && i+1 < len(cmd.args):
+			w, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil || w < 1 {
+				return fmt.Errorf("invalid workers: %s", cmd.args[i+1])
+			}
+			opts.workers = w
+			i++ // skip the value
+		case cmd.args[i] == "--batch" && i+1 < len(cmd.args):
+			b, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil || b < 1 {
+				return fmt.Errorf("invalid batch: %s", cmd.args[i+1])
+			}
+			opts.batchSize = b
+			i++ // skip the value
+		case cmd.args[i] == "--lease" && i+1 < len(cmd.args):
+			l, err := time.ParseDuration(cmd.args[i+1])
+			if err != nil || l < time.Second {
+				return fmt.Errorf("invalid lease: %s", cmd.args[i+1])
+			}
+			opts.lease = l
+			i++ // skip the value
+		case cmd.args[i] == "--max-failures" && i+1 < len(cmd.args):
+			m, err := strconv.Atoi(cmd.args[i+1])
+			if err != nil || m < 0 {
+				return fmt.Errorf("invalid max failures: %s", cmd.args[i+1])
+			}
+			opts.maxFailures = m
+			i++ // skip the value
+		case cmd.args[i] == "--drain-timeout" && i+1 < len(cmd.args):
+			d, err := time.ParseDuration(cmd.args[i+1])
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid drain timeout: %s", cmd.args[i+1])
+			}
+			opts.drainTimeout = d
+			i++ // skip the value
+		case cmd.args[i] == "--once":
+			once = true
+		case cmd.args[i] == "--keep-history":
+			opts.keepHistory = true
+		case cmd.args[i] == "--feed" && i+1 < len(cmd.args):
+			feedURL = cmd.args[i+1]
+			i++ // skip the value
+		case cmd.args[i] == "--max-duration" && i+1 < len(cmd.args):
+			d, err := time.ParseDuration(cmd.args[i+1])
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid max duration: %s", cmd.args[i+1])
+			}
+			maxDuration = d
+			i++ // skip the value
+		default:
+			positional = append(positional, cmd.args[i])
+		}
+	}
+
+	// The ticker interval is only needed when running continuously.
+	continuous := !once && feedURL == ""
+	if (continuous && len(positional) != 1) || (!continuous && len(positional) != 0) {
+		return fmt.Errorf("usage: agg <time_between_reqs> | --once | --feed <feed_url> " +
+			"[--max-duration DURATION] [--workers N] [--batch N] [--lease DURATION] " +
+			"[--max-failures N] [--drain-timeout DURATION] [--keep-history]")
+	}
+
+	if maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, maxDuration)
+		defer cancel()
+	}
+
+	var stats aggStats
+	var err error
+	switch {
+	case feedURL != "":
+		err = aggFeed(ctx, s, opts, feedURL, &stats)
+	case once:
+		err = aggOnce(ctx, s, opts, &stats)
+	default:
+		opts.interval, err = time.ParseDuration(positional[0])
+		if err != nil {
+			return err
+		}
+		aggContinuously(ctx, s, opts, &stats)
 	}
-	duration, err := time.ParseDuration(cmd.args[0])
+	fmt.Println("Summary:", stats.String())
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Collecting feeds every %s\n", duration)
-	ticker := time.NewTicker(duration)
+
+	// Bounded runs are meant for cron and CI, so failed feeds must show
+	// up in the exit code.
+	if (!continuous || maxDuration > 0) && stats.feedsFailed.Load() > 0 {
+		return fmt.Errorf("%d feeds failed", stats.feedsFailed.Load())
+	}
+	return nil
+}
+
+// aggContinuously fetches due feeds every opts.interval until ctx is done.
+func aggContinuously(ctx context.Context, s *state, opts aggOptions, stats *aggStats) {
+	fmt.Printf("Collecting %d feeds every %s with %d workers\n", opts.batchSize, opts.interval, opts.workers)
+	ticker := time.NewTicker(opts.interval)
 	defer ticker.Stop()
 
 	for {
-		err := scrapeFeeds(s)
-		if err != nil {
+		err := scrapeFeeds(ctx, s, opts, stats)
+		if err != nil && ctx.Err() == nil {
 			fmt.Println("scrape error:", err)
 		}
 
-		<-ticker.C
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// aggOnce fetches batches of due feeds until none are left, then returns.
+// Each feed is fetched at most once: one that is due again, because its
+// schedule couldn't be advanced, is skipped but kept claimed until the run
+// ends, so that the next batch moves on to the feeds behind it.
+func aggOnce(ctx context.Context, s *state, opts aggOptions, stats *aggStats) error {
+	seen := make(map[uuid.UUID]bool)
+	skipped := make(map[uuid.UUID]database.ClaimFeedsToFetchRow)
+	defer func() {
+		for _, feed := range skipped {
+			releaseFeedClaim(ctx, s, feed)
+		}
+	}()
+
+	for ctx.Err() == nil {
+		claimed, err := claimDueFeeds(ctx, s, opts)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		if len(claimed) == 0 {
+			return nil
+		}
+
+		feeds := make([]database.ClaimFeedsToFetchRow, 0, len(claimed))
+		for _, feed := range claimed {
+			if !seen[feed.ID] {
+				seen[feed.ID] = true
+				feeds = append(feeds, feed)
+				continue
+			}
+			// Its lease may have run out during a long run, in which case
+			// it comes back once more.
+			if _, ok := skipped[feed.ID]; !ok {
+				stats.feedsSkipped.Add(1)
+			}
+			skipped[feed.ID] = feed
+		}
+		scrapeClaimedFeeds(ctx, s, opts, stats, feeds)
+	}
+	return nil
+}
+
+// aggFeed fetches a single feed right away, whether or not it is due.
+func aggFeed(ctx context.Context, s *state, opts aggOptions, feedURL string, stats *aggStats) error {
+	feed, err := s.db.GetFeedByURL(ctx, feedURL)
+	if err != nil {
+		return fmt.Errorf("feed not found for url %s", feedURL)
 	}
+	claimed, err := s.db.ClaimFeed(ctx, database.ClaimFeedParams{
+		ID:           feed.ID,
+		LeaseSeconds: int32(opts.lease / time.Second),
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("feed %s is being fetched by another process", feed.Name)
+		}
+		return err
+	}
+
+	workCtx, cancelWork := drainContext(ctx, opts.drainTimeout)
+	defer cancelWork()
+
+	row := database.ClaimFeedsToFetchRow(claimed)
+	report, err := scrapeFeed(workCtx, s, row, opts)
+	stats.record(row, report, err)
+	return nil
 }
 
-func handlerGetUsers(s *state, cmd command) error {
+func handlerGetUsers(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 0 {
 		return fmt.Errorf("users does not take any arguments")
 	}
-	ctx := context.Background()
 	users, err := s.db.GetUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get all users: %w", err)
 	}
+	if s.output != outputText {
+		records := make([]userRecord, 0, len(users))
+		for _, user := range users {
+			records = append(records, userRecord{
+				ID:        user.ID,
+				Name:      user.Name,
+				CreatedAt: user.CreatedAt.UTC(),
+				Current:   s.cfg.CurrentUserName == user.Name,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
 	for _, user := range users {
 		if s.cfg.CurrentUserName == user.Name {
 			fmt.Printf("* %s (current)\n", user.Name)
@@ -330,29 +1692,26 @@ func handlerGetUsers(s *state, cmd command) error {
 	return nil
 }
 
-func handlerReset(s *state, cmd command) error {
+func handlerReset(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 0 {
 		return fmt.Errorf("reset does not take any arguments")
 	}
-	ctx := context.Background()
 	err := s.db.DeleteAllUsers(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to reset users: %w", err)
 	}
-	s.cfg.CurrentUserName = ""
-	if err := config.Write(*s.cfg); err != nil {
+	if err := s.cfg.SetUser(""); err != nil {
 		return fmt.Errorf("failed to clear config: %w", err)
 	}
 	fmt.Println("All users have been deleted.")
 	return nil
 }
 
-func handlerRegister(s *state, cmd command) error {
+func handlerRegister(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 1 {
 		return fmt.Errorf("username is required")
 	}
 	username := cmd.args[0]
-	ctx := context.Background()
 	_, err := s.db.GetUserByName(ctx, username)
 	if err == nil {
 		// User exists
@@ -378,20 +1737,18 @@ func handlerRegister(s *state, cmd command) error {
 	fmt.Printf("  Name:      %s\n", user.Name)
 	fmt.Printf("  CreatedAt: %s\n", user.CreatedAt)
 	fmt.Printf("  UpdatedAt: %s\n", user.UpdatedAt)
-	s.cfg.CurrentUserName = username
-	if err := config.Write(*s.cfg); err != nil {
+	if err := s.cfg.SetUser(username); err != nil {
 		return fmt.Errorf("failed to update config: %w", err)
 	}
 	fmt.Printf("Current user set to: %s\n", username)
 	return nil
 }
 
-func handlerLogin(s *state, cmd command) error {
+func handlerLogin(ctx context.Context, s *state, cmd command) error {
 	if len(cmd.args) != 1 {
 		return fmt.Errorf("username is required")
 	}
 	username := cmd.args[0]
-	ctx := context.Background()
 	user, err := s.db.GetUserByName(ctx, username)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -400,8 +1757,7 @@ func handlerLogin(s *state, cmd command) error {
 		}
 		return fmt.Errorf("failed to check username: %w", err)
 	}
-	s.cfg.CurrentUserName = user.Name
-	if err = config.Write(*s.cfg); err != nil {
+	if err = s.cfg.SetUser(user.Name); err != nil {
 		return fmt.Errorf("failed to update config: %w", err)
 	}
 
@@ -409,14 +1765,180 @@ func handlerLogin(s *state, cmd command) error {
 	return nil
 }
 
-func (c *commands) run(s *state, cmd command) error {
+func handlerConfig(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: config <show|init>")
+	}
+	sub := command{
+		name: cmd.name + " " + cmd.args[0],
+		args: cmd.args[1:],
+	}
+	switch cmd.args[0] {
+	case "show":
+		return handlerConfigShow(ctx, s, sub)
+	case "init":
+		return handlerConfigInit(ctx, s, sub)
+	default:
+		return fmt.Errorf("unknown config command: %s", cmd.args[0])
+	}
+}
+
+func handlerConfigShow(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 0 {
+		return fmt.Errorf("usage: config show")
+	}
+	settings := s.cfg.Settings()
+	if s.output != outputText {
+		records := make([]settingRecord, 0, len(settings))
+		for _, setting := range settings {
+			records = append(records, settingRecord{
+				Name:   setting.Name,
+				Value:  setting.Value,
+				Source: setting.Source.String(),
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	fmt.Println("Config file:", s.cfg.Path())
+	for _, setting := range settings {
+		fmt.Printf("%s = %s (from %s)\n", setting.Name, setting.Value, setting.Source)
+	}
+	return nil
+}
+
+func handlerConfigInit(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 0 {
+		return fmt.Errorf("usage: config init")
+	}
+	fmt.Println("Config file:", s.cfg.Path())
+
+	for {
+		dbURL, err := prompt("Database URL", s.cfg.DBurl, config.RedactPassword(s.cfg.DBurl))
+		if err != nil {
+			return err
+		}
+		if err := s.cfg.SetDBURL(dbURL); err != nil {
+			fmt.Println(err)
+			continue
+		}
+		break
+	}
+
+	// A bad URL is still saved, since the database may just not be
+	// running yet.
+	db, err := storage.Open(s.cfg.DBurl)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	defer db.Close()
+	pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := db.SQL.PingContext(pingCtx); err != nil {
+		fmt.Println("Warning: could not connect to the database:", err)
+	} else {
+		fmt.Println("Connected to the database.")
+	}
+
+	fmt.Println("Config saved.")
+	return nil
+}
+
+func handlerProfile(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: profile <list|use|add|rm> ...")
+	}
+	sub := command{
+		name: cmd.name + " " + cmd.args[0],
+		args: cmd.args[1:],
+	}
+	switch cmd.args[0] {
+	case "list":
+		return handlerProfileList(ctx, s, sub)
+	case "use":
+		return handlerProfileUse(ctx, s, sub)
+	case "add":
+		return handlerProfileAdd(ctx, s, sub)
+	case "rm":
+		return handlerProfileRemove(ctx, s, sub)
+	default:
+		return fmt.Errorf("unknown profile command: %s", cmd.args[0])
+	}
+}
+
+func handlerProfileList(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 0 {
+		return fmt.Errorf("usage: profile list")
+	}
+	profiles := s.cfg.Profiles()
+	if s.output != outputText {
+		records := make([]profileRecord, 0, len(profiles))
+		for _, profile := range profiles {
+			records = append(records, profileRecord{
+				Name:            profile.Name,
+				DBURL:           profile.DBurl,
+				CurrentUserName: profile.CurrentUserName,
+				Active:          profile.Active,
+			})
+		}
+		return writeRecords(os.Stdout, s.output, records)
+	}
+
+	for _, profile := range profiles {
+		marker := " "
+		if profile.Active {
+			marker = "*"
+		}
+		user := profile.CurrentUserName
+		if user == "" {
+			user = "not logged in"
+		}
+		fmt.Printf("%s %s: %s (%s)\n", marker, profile.Name, profile.DBurl, user)
+	}
+	return nil
+}
+
+func handlerProfileUse(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: profile use <name>")
+	}
+	if err := s.cfg.UseProfile(cmd.args[0]); err != nil {
+		return err
+	}
+	fmt.Println("Using profile:", cmd.args[0])
+	return nil
+}
+
+func handlerProfileAdd(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 2 {
+		return fmt.Errorf("usage: profile add <name> <db_url>")
+	}
+	if err := s.cfg.AddProfile(cmd.args[0], cmd.args[1]); err != nil {
+		return err
+	}
+	fmt.Println("Profile added:", cmd.args[0])
+	return nil
+}
+
+func handlerProfileRemove(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) != 1 {
+		return fmt.Errorf("usage: profile rm <name>")
+	}
+	if err := s.cfg.RemoveProfile(cmd.args[0]); err != nil {
+		return err
+	}
+	fmt.Println("Profile removed:", cmd.args[0])
+	return nil
+}
+
+func (c *commands) run(ctx context.Context, s *state, cmd command) error {
 	handler, ok := c.handlers[cmd.name]
 	if !ok {
 		return fmt.Errorf("unknown command: %s", cmd.name)
 	}
-	return handler(s, cmd)
+	return handler(ctx, s, cmd)
 }
 
-func (c *commands) register(name string, f func(*state, command) error) {
+func (c *commands) register(name string, f func(context.Context, *state, command) error) {
 	c.handlers[name] = f
 }
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"html"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/akigithub888/aggreGATOR/internal/storage"
+	"github.com/google/uuid"
+	"github.com/ncruces/go-sqlite3/driver"
+	"github.com/ncruces/go-sqlite3/ext/hash"
+)
+
+const legacyRSSFeed = `<?xml version="1.0"?>
+<rss version="2.0">
+  <channel>
+    <title>Legacy</title>
+    <item>
+      <title>Padded link &amp;amp; guid</title>
+      <link>
+        https://example.com/padded
+      </link>
+      <guid isPermaLink="false">padded</guid>
+    </item>
+    <item>
+      <title>Permalink guid only</title>
+      <guid>https://example.com/permalink</guid>
+    </item>
+    <item>
+      <title>No guid</title>
+      <link> https://example.com/no-guid </link>
+    </item>
+  </channel>
+</rss>`
+
+// TestSavePostAfterGUIDBackfill stores posts the way gator did before
+// posts had GUIDs, runs the backfill of sql/schema/010_add_guid_to_posts.sql
+// on them and then fetches the same feed again, which must not insert any
+// of its items a second time.
+func TestSavePostAfterGUIDBackfill(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "gator.db")
+	db, err := storage.Open(storage.SQLiteScheme + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	provider, err := newMigrationProvider(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.Up(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Now()
+	user, err := db.CreateUser(ctx, database.CreateUserParams{ID: uuid.New(), CreatedAt: now, UpdatedAt: now, Name: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	feed, err := db.CreateFeed(ctx, database.CreateFeedParams{
+		ID:        uuid.New(),
+		CreatedAt: now,
+		UpdatedAt: now,
+		Name:      "Legacy",
+		Url:       "https://example.com/feed.xml",
+		UserID:    user.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Before GUIDs, posts were stored with the item's <link> as decoded,
+	// untrimmed and empty when missing, and its unescaped title.
+	var legacy RSSFeed
+	if err := xml.Unmarshal([]byte(legacyRSSFeed), &legacy); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range legacy.Channel.Item {
+		_, err := db.SQL.ExecContext(ctx,
+			`INSERT INTO posts (id, created_at, updated_at, title, url, feed_id, guid) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			uuid.New(), now, now, html.UnescapeString(item.Title), item.Link, feed.ID, uuid.NewString())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The backfill of migration 010, in SQLite.
+	hashDB, err := driver.Open("file:"+path+"?_pragma=busy_timeout(10000)", hash.Register)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hashDB.Close()
+	_, err = hashDB.ExecContext(ctx, `UPDATE posts SET guid = lower(hex(sha256(CAST(url || char(10) || title AS BLOB))))`)
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+
+	parsed, err := parseFeed("application/rss+xml", []byte(legacyRSSFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &state{db: db.Store, conn: db}
+	claimed := database.ClaimFeedsToFetchRow{ID: feed.ID, Name: feed.Name, Url: feed.Url}
+	for _, item := range parsed.Items {
+		outcome, err := savePost(ctx, s, claimed, item, aggOptions{})
+		if err != nil {
+			t.Fatalf("savePost(%q): %v", item.Title, err)
+		}
+		if outcome == postInserted {
+			t.Errorf("savePost(%q) inserted the post again", item.Title)
+		}
+	}
+
+	var posts int
+	if err := db.SQL.QueryRowContext(ctx, `SELECT count(*) FROM posts`).Scan(&posts); err != nil {
+		t.Fatal(err)
+	}
+	if posts != len(legacy.Channel.Item) {
+		t.Errorf("%d posts after fetching again, want %d", posts, len(legacy.Channel.Item))
+	}
+	for _, item := range parsed.Items {
+		if item.ID == "" {
+			continue
+		}
+		var adopted int
+		err := db.SQL.QueryRowContext(ctx, `SELECT count(*) FROM posts WHERE guid = ?`, item.ID).Scan(&adopted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if adopted != 1 {
+			t.Errorf("post %q wasn't switched over to its guid %q", item.Title, item.ID)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfigFile = `{
+  "db_url": "sqlite:///tmp/top.db",
+  "current_user_name": "alice",
+  "output": "table",
+  "profiles": {
+    "work": {
+      "db_url": "sqlite:///tmp/work.db"
+    },
+    "staging": {
+      "db_url": "postgres://gator@staging/gator",
+      "current_user_name": "carol",
+      "output": "json"
+    }
+  }
+}
+`
+
+// writeTestConfig writes contents to a config file in a temporary
+// directory and clears the environment variables Load reads.
+func writeTestConfig(t *testing.T, contents string) string {
+	t.Helper()
+	for _, name := range []string{"GATOR_CONFIG", "GATOR_PROFILE", "GATOR_DB_URL", "GATOR_CURRENT_USER_NAME", "GATOR_OUTPUT"} {
+		t.Setenv(name, "")
+	}
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		opts    Options
+		profile string
+		dbURL   string
+		user    string
+		output  string
+		sources map[string]string
+	}{
+		{
+			name:    "default profile",
+			profile: DefaultProfile,
+			dbURL:   "sqlite:///tmp/top.db",
+			user:    "alice",
+			output:  "table",
+			sources: map[string]string{"db_url": "file", "current_user_name": "file", "output": "file"},
+		},
+		{
+			name:    "named profile doesn't inherit the current user",
+			opts:    Options{Profile: "work"},
+			profile: "work",
+			dbURL:   "sqlite:///tmp/work.db",
+			user:    "",
+			output:  "table",
+			sources: map[string]string{"db_url": "file", "current_user_name": "default", "output": "file"},
+		},
+		{
+			name:    "named profile with its own settings",
+			env:     map[string]string{"GATOR_PROFILE": "staging"},
+			profile: "staging",
+			dbURL:   "postgres://gator@staging/gator",
+			user:    "carol",
+			output:  "json",
+		},
+		{
+			name: "env overrides file",
+			env: map[string]string{
+				"GATOR_PROFILE":           "work",
+				"GATOR_DB_URL":            "sqlite:///tmp/env.db",
+				"GATOR_CURRENT_USER_NAME": "dave",
+			},
+			profile: "work",
+			dbURL:   "sqlite:///tmp/env.db",
+			user:    "dave",
+			output:  "table",
+			sources: map[string]string{"db_url": "env", "current_user_name": "env", "output": "file"},
+		},
+		{
+			name: "flag overrides env",
+			env: map[string]string{
+				"GATOR_PROFILE": "work",
+				"GATOR_DB_URL":  "sqlite:///tmp/env.db",
+				"GATOR_OUTPUT":  "csv",
+			},
+			opts:    Options{Profile: "staging", DBURL: "sqlite:///tmp/flag.db", Output: "jsonl"},
+			profile: "staging",
+			dbURL:   "sqlite:///tmp/flag.db",
+			user:    "carol",
+			output:  "jsonl",
+			sources: map[string]string{"db_url": "flag", "current_user_name": "file", "output": "flag"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Path = writeTestConfig(t, testConfigFile)
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := Load(tt.opts)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if got := cfg.Profile(); got != tt.profile {
+				t.Errorf("profile = %q, want %q", got, tt.profile)
+			}
+			if cfg.DBurl != tt.dbURL {
+				t.Errorf("db_url = %q, want %q", cfg.DBurl, tt.dbURL)
+			}
+			if cfg.CurrentUserName != tt.user {
+				t.Errorf("current_user_name = %q, want %q", cfg.CurrentUserName, tt.user)
+			}
+			if cfg.Output != tt.output {
+				t.Errorf("output = %q, want %q", cfg.Output, tt.output)
+			}
+			for name, layer := range tt.sources {
+				if got := cfg.sources[name].Layer; got != layer {
+					t.Errorf("%s comes from %q, want %q", name, got, layer)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnknownProfile(t *testing.T) {
+	path := writeTestConfig(t, testConfigFile)
+	if _, err := Load(Options{Path: path, Profile: "nope"}); err == nil {
+		t.Fatal("Load with an unknown profile succeeded")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		parseErr bool
+	}{
+		{name: "invalid JSON", contents: `{"db_url": `, parseErr: true},
+		{name: "wrong type", contents: `{"db_url": 3}`, parseErr: true},
+		{name: "unknown key", contents: `{"db": "sqlite:///tmp/x.db"}`, parseErr: true},
+		{name: "invalid db_url", contents: `{"db_url": "mysql://localhost/gator"}`},
+		{name: "invalid output", contents: `{"output": "yaml"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestConfig(t, tt.contents)
+			_, err := Load(Options{Path: path})
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			var parseErr *ParseError
+			if got := errors.As(err, &parseErr); got != tt.parseErr {
+				t.Errorf("Load error %q is a ParseError: %v, want %v", err, got, tt.parseErr)
+			}
+		})
+	}
+}
+
+func TestSetUser(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		user    string
+	}{
+		{name: "log in on default profile", profile: DefaultProfile, user: "bob"},
+		{name: "log out of default profile", profile: DefaultProfile, user: ""},
+		{name: "log in on named profile", profile: "work", user: "bob"},
+		{name: "log out of named profile", profile: "staging", user: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeTestConfig(t, testConfigFile)
+			cfg, err := Load(Options{Path: path, Profile: tt.profile})
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if err := cfg.SetUser(tt.user); err != nil {
+				t.Fatalf("SetUser: %v", err)
+			}
+
+			cfg, err = Load(Options{Path: path, Profile: tt.profile})
+			if err != nil {
+				t.Fatalf("Load after SetUser: %v", err)
+			}
+			if cfg.CurrentUserName != tt.user {
+				t.Errorf("current_user_name = %q after SetUser(%q)", cfg.CurrentUserName, tt.user)
+			}
+			if tt.profile != DefaultProfile {
+				top, err := Load(Options{Path: path, Profile: DefaultProfile})
+				if err != nil {
+					t.Fatalf("Load default profile: %v", err)
+				}
+				if top.CurrentUserName != "alice" {
+					t.Errorf("default profile's user = %q, want it left as %q", top.CurrentUserName, "alice")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultProfile is the profile made up of the config file's top-level
+// settings. It always exists.
+const DefaultProfile = "default"
+
+// ProfileInfo describes a profile, for display. DBurl has its password
+// redacted.
+type ProfileInfo struct {
+	Name            string
+	DBurl           string
+	CurrentUserName string
+	Active          bool
+}
+
+// selectProfile picks the active profile from the --profile flag,
+// $GATOR_PROFILE or the config file, in that order.
+func (c *Config) selectProfile(opts Options) error {
+	c.profile, c.profileSource = DefaultProfile, Source{Layer: "default"}
+	if c.file.Profile != "" {
+		c.profile, c.profileSource = c.file.Profile, Source{Layer: "file", Name: c.path}
+	}
+	if v := os.Getenv("GATOR_PROFILE"); v != "" {
+		c.profile, c.profileSource = v, Source{Layer: "env", Name: "GATOR_PROFILE"}
+	}
+	if opts.Profile != "" {
+		c.profile, c.profileSource = opts.Profile, Source{Layer: "flag", Name: "--profile"}
+	}
+	if c.profile != DefaultProfile && c.file.Profiles[c.profile] == nil {
+		return fmt.Errorf("unknown profile %q from %s", c.profile, c.profileSource)
+	}
+	return nil
+}
+
+// section is the part of the config file that holds the active profile's
+// settings.
+func (c *Config) section() *profileConfig {
+	if c.profile == DefaultProfile {
+		return &c.file.profileConfig
+	}
+	return c.file.Profiles[c.profile]
+}
+
+// Profile is the name of the active profile.
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// Profiles lists the default profile followed by the named ones.
+func (c *Config) Profiles() []ProfileInfo {
+	names := make([]string, 0, len(c.file.Profiles))
+	for name := range c.file.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := []ProfileInfo{c.profileInfo(DefaultProfile, &c.file.profileConfig)}
+	for _, name := range names {
+		list = append(list, c.profileInfo(name, c.file.Profiles[name]))
+	}
+	return list
+}
+
+// profileInfo fills in what a profile inherits from the top level.
+func (c *Config) profileInfo(name string, p *profileConfig) ProfileInfo {
+	info := ProfileInfo{
+		Name:            name,
+		DBurl:           p.DBurl,
+		CurrentUserName: p.CurrentUserName,
+		Active:          name == c.profile,
+	}
+	if info.DBurl == "" {
+		info.DBurl = c.file.DBurl
+	}
+	if info.DBurl == "" {
+		info.DBurl = DefaultDBURL
+	}
+	info.DBurl = RedactPassword(info.DBurl)
+	return info
+}
+
+// UseProfile makes name the profile used from now on.
+func (c *Config) UseProfile(name string) error {
+	if name != DefaultProfile && c.file.Profiles[name] == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	c.file.Profile = name
+	if name == DefaultProfile {
+		c.file.Profile = ""
+	}
+	return c.write()
+}
+
+// AddProfile creates a profile that connects to dbURL.
+func (c *Config) AddProfile(name, dbURL string) error {
+	if name == "" || strings.ContainsAny(name, " \t\n") {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	if name == DefaultProfile || c.file.Profiles[name] != nil {
+		return fmt.Errorf("profile %q already exists", name)
+	}
+	if err := validateDBURL(dbURL); err != nil {
+		return fmt.Errorf("invalid db_url: %w", err)
+	}
+	if c.file.Profiles == nil {
+		c.file.Profiles = make(map[string]*profileConfig)
+	}
+	c.file.Profiles[name] = &profileConfig{DBurl: dbURL}
+	return c.write()
+}
+
+// RemoveProfile deletes a named profile. If it was the one in use, the
+// default profile is used from now on.
+func (c *Config) RemoveProfile(name string) error {
+	if name == DefaultProfile {
+		return fmt.Errorf("the %s profile can't be removed", DefaultProfile)
+	}
+	if c.file.Profiles[name] == nil {
+		return fmt.Errorf("unknown profile %q", name)
+	}
+	delete(c.file.Profiles, name)
+	if c.file.Profile == name {
+		c.file.Profile = ""
+	}
+	return c.write()
+}
@@ -13,26 +13,200 @@ import (
 	"github.com/google/uuid"
 )
 
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET
+    updated_at = NOW(),
+    claimed_until = NOW() + make_interval(secs => $1::int)
+WHERE id = $2
+  AND (claimed_until IS NULL OR claimed_until < NOW())
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds
+`
+
+type ClaimFeedParams struct {
+	LeaseSeconds int32
+	ID           uuid.UUID
+}
+
+type ClaimFeedRow struct {
+	ID                    uuid.UUID
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ConsecutiveFailures   int32
+	FetchIntervalSeconds  sql.NullInt32
+	HintedIntervalSeconds sql.NullInt32
+}
+
+// Claims a single feed regardless of its schedule or disabled state, for
+// explicit refreshes. Fails with no rows if another process holds it.
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (ClaimFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.LeaseSeconds, arg.ID)
+	var i ClaimFeedRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+	)
+	return i, err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET
+    updated_at = NOW(),
+    claimed_until = NOW() + make_interval(secs => $1::int)
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (claimed_until IS NULL OR claimed_until < NOW())
+      AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT $2
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds
+`
+
+type ClaimFeedsToFetchParams struct {
+	LeaseSeconds int32
+	BatchSize    int32
+}
+
+type ClaimFeedsToFetchRow struct {
+	ID                    uuid.UUID
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ConsecutiveFailures   int32
+	FetchIntervalSeconds  sql.NullInt32
+	HintedIntervalSeconds sql.NullInt32
+}
+
+// Locks and leases a batch of due feeds in one statement, so concurrent
+// agg processes never pick the same feed. Feeds whose lease has run out
+// (e.g. the claiming process crashed) can be claimed again. Disabled
+// feeds are skipped.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]ClaimFeedsToFetchRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimFeedsToFetchRow
+	for rows.Next() {
+		var i ClaimFeedsToFetchRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.FetchIntervalSeconds,
+			&i.HintedIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countFeedDependents = `-- name: CountFeedDependents :one
+SELECT
+    (SELECT COUNT(*) FROM posts WHERE posts.feed_id = $1) AS post_count,
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = $1) AS follow_count
+`
+
+type CountFeedDependentsRow struct {
+	PostCount   int64
+	FollowCount int64
+}
+
+func (q *Queries) CountFeedDependents(ctx context.Context, feedID uuid.UUID) (CountFeedDependentsRow, error) {
+	row := q.db.QueryRowContext(ctx, countFeedDependents, feedID)
+	var i CountFeedDependentsRow
+	err := row.Scan(&i.PostCount, &i.FollowCount)
+	return i, err
+}
+
 const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, fetch_interval_seconds)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
 )
-RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, claimed_until, last_error, last_error_at, consecutive_failures, disabled_at, next_fetch_at, fetch_interval_seconds, hinted_interval_seconds, disabled_reason
 `
 
 type CreateFeedParams struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	Name      string
-	Url       string
-	UserID    uuid.UUID
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	FetchIntervalSeconds sql.NullInt32
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
@@ -43,6 +217,7 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		arg.Name,
 		arg.Url,
 		arg.UserID,
+		arg.FetchIntervalSeconds,
 	)
 	var i Feed
 	err := row.Scan(
@@ -53,10 +228,71 @@ func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, e
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ClaimedUntil,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+		&i.DisabledReason,
 	)
 	return i, err
 }
 
+const createFeedFetch = `-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+    id,
+    feed_id,
+    started_at,
+    finished_at,
+    status,
+    items,
+    inserted,
+    updated,
+    duplicates,
+    failed,
+    error
+)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+)
+`
+
+type CreateFeedFetchParams struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Items      int32
+	Inserted   int32
+	Updated    int32
+	Duplicates int32
+	Failed     int32
+	Error      sql.NullString
+}
+
+func (q *Queries) CreateFeedFetch(ctx context.Context, arg CreateFeedFetchParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedFetch,
+		arg.ID,
+		arg.FeedID,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.Status,
+		arg.Items,
+		arg.Inserted,
+		arg.Updated,
+		arg.Duplicates,
+		arg.Failed,
+		arg.Error,
+	)
+	return err
+}
+
 const createFeedFollow = `-- name: CreateFeedFollow :one
 WITH inserted_feed_follow AS (
     INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
@@ -121,6 +357,27 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 	return i, err
 }
 
+const deleteFeed = `-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = $1
+  AND user_id = $2
+`
+
+type DeleteFeedParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// Deletes a feed owned by user_id. Its posts, follows and fetch history
+// go with it; saved copies of its posts are kept.
+func (q *Queries) DeleteFeed(ctx context.Context, arg DeleteFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeed, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
 DELETE FROM feed_follows
 WHERE user_id = $1
@@ -137,10 +394,31 @@ func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowPara
 	return err
 }
 
+const enableFeed = `-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+// Clears the feed's failures and makes it due right away, rather than
+// at the retry time its last failure set.
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, id)
+	return err
+}
+
 const getFeedByURL = `-- name: GetFeedByURL :one
-SELECT id, name, url, created_at, updated_at, user_id
+SELECT feeds.id, feeds.name, feeds.url, feeds.created_at, feeds.updated_at, feeds.user_id
 FROM feeds
-WHERE url = $1
+WHERE feeds.url = $1
+   OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+ORDER BY feeds.url = $1 DESC
+LIMIT 1
 `
 
 type GetFeedByURLRow struct {
@@ -152,6 +430,8 @@ type GetFeedByURLRow struct {
 	UserID    uuid.UUID
 }
 
+// Also finds feeds that have moved away from url. A feed currently at
+// url wins over one that used to be there.
 func (q *Queries) GetFeedByURL(ctx context.Context, url string) (GetFeedByURLRow, error) {
 	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
 	var i GetFeedByURLRow
@@ -174,7 +454,19 @@ SELECT
     feed_follows.user_id,
     feed_follows.feed_id,
     users.name AS user_name,
-    feeds.name AS feed_name
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    (
+        SELECT COUNT(*)
+        FROM posts
+        WHERE posts.feed_id = feed_follows.feed_id
+          AND NOT EXISTS (
+              SELECT 1
+              FROM post_reads
+              WHERE post_reads.post_id = posts.id
+                AND post_reads.user_id = feed_follows.user_id
+          )
+    ) AS unread_count
 FROM feed_follows
 JOIN users ON users.id = feed_follows.user_id
 JOIN feeds ON feeds.id = feed_follows.feed_id
@@ -183,13 +475,15 @@ ORDER BY feed_follows.created_at DESC
 `
 
 type GetFeedFollowsForUserRow struct {
-	ID        uuid.UUID
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    uuid.UUID
-	FeedID    uuid.UUID
-	UserName  string
-	FeedName  string
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	UserName    string
+	FeedName    string
+	FeedUrl     string
+	UnreadCount int64
 }
 
 func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
@@ -209,6 +503,8 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 			&i.FeedID,
 			&i.UserName,
 			&i.FeedName,
+			&i.FeedUrl,
+			&i.UnreadCount,
 		); err != nil {
 			return nil, err
 		}
@@ -225,18 +521,28 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) (
 
 const getFeeds = `-- name: GetFeeds :many
 SELECT
+    feeds.id AS feed_id,
     feeds.name AS feed_name,
     feeds.url AS feed_url,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.created_at,
+    feeds.last_fetched_at,
+    feeds.next_fetch_at,
+    feeds.disabled_at
 FROM feeds
 JOIN users ON feeds.user_id = users.id
 ORDER BY feeds.created_at
 `
 
 type GetFeedsRow struct {
-	FeedName string
-	FeedUrl  string
-	UserName string
+	FeedID        uuid.UUID
+	FeedName      string
+	FeedUrl       string
+	UserName      string
+	CreatedAt     time.Time
+	LastFetchedAt sql.NullTime
+	NextFetchAt   sql.NullTime
+	DisabledAt    sql.NullTime
 }
 
 func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
@@ -248,7 +554,16 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	var items []GetFeedsRow
 	for rows.Next() {
 		var i GetFeedsRow
-		if err := rows.Scan(&i.FeedName, &i.FeedUrl, &i.UserName); err != nil {
+		if err := rows.Scan(
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+			&i.CreatedAt,
+			&i.LastFetchedAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -262,54 +577,271 @@ func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
+const getFeedsWithErrors = `-- name: GetFeedsWithErrors :many
 SELECT
-    id,
-    name,
-    url,
-    user_id,
-    last_fetched_at,
-    created_at,
-    updated_at
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.last_error,
+    feeds.last_error_at,
+    feeds.consecutive_failures,
+    feeds.disabled_at,
+    feeds.disabled_reason
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1
+JOIN users ON feeds.user_id = users.id
+WHERE feeds.consecutive_failures > 0
+   OR feeds.disabled_at IS NOT NULL
+ORDER BY feeds.disabled_at ASC NULLS LAST, feeds.consecutive_failures DESC
 `
 
-type GetNextFeedToFetchRow struct {
-	ID            uuid.UUID
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+type GetFeedsWithErrorsRow struct {
+	FeedID              uuid.UUID
+	FeedName            string
+	FeedUrl             string
+	UserName            string
+	LastError           sql.NullString
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+	DisabledReason      sql.NullString
 }
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (GetNextFeedToFetchRow, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
-	var i GetNextFeedToFetchRow
-	err := row.Scan(
-		&i.ID,
-		&i.Name,
-		&i.Url,
-		&i.UserID,
-		&i.LastFetchedAt,
-		&i.CreatedAt,
-		&i.UpdatedAt,
+func (q *Queries) GetFeedsWithErrors(ctx context.Context) ([]GetFeedsWithErrorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsWithErrorsRow
+	for rows.Next() {
+		var i GetFeedsWithErrorsRow
+		if err := rows.Scan(
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.DisabledReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeedURL = `-- name: MoveFeedURL :execrows
+WITH moved AS (
+    UPDATE feeds
+    SET
+        url = $2,
+        updated_at = NOW()
+    WHERE feeds.id = $3
+      AND feeds.url = $1
+      AND NOT EXISTS (
+          SELECT 1
+          FROM feeds AS other
+          WHERE other.url = $2
+      )
+    RETURNING feeds.id
+),
+returned AS (
+    DELETE FROM feed_aliases
+    WHERE feed_aliases.url = $2
+      AND feed_aliases.feed_id IN (SELECT id FROM moved)
+)
+INSERT INTO feed_aliases (url, created_at, feed_id)
+SELECT $1, NOW(), moved.id
+FROM moved
+ON CONFLICT (url) DO UPDATE
+SET feed_id = EXCLUDED.feed_id
+`
+
+type MoveFeedURLParams struct {
+	OldUrl string
+	NewUrl string
+	ID     uuid.UUID
+}
+
+// Moves a feed that redirected permanently from old_url to new_url and
+// keeps old_url as an alias. Nothing happens if another feed is already
+// at new_url.
+func (q *Queries) MoveFeedURL(ctx context.Context, arg MoveFeedURLParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveFeedURL, arg.OldUrl, arg.NewUrl, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET
+    last_error = $1::text,
+    last_error_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => $2::int),
+    consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE
+        WHEN $3::int > 0
+             AND consecutive_failures + 1 >= $3::int
+        THEN NOW()
+        ELSE disabled_at
+    END,
+    disabled_reason = CASE
+        WHEN $3::int > 0
+             AND consecutive_failures + 1 >= $3::int
+        THEN $4::text
+        ELSE disabled_reason
+    END,
+    updated_at = NOW()
+WHERE id = $5
+RETURNING consecutive_failures, disabled_at
+`
+
+type RecordFeedFailureParams struct {
+	LastError          string
+	NextFetchInSeconds int32
+	MaxFailures        int32
+	DisabledReason     string
+	ID                 uuid.UUID
+}
+
+type RecordFeedFailureRow struct {
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+// Disables the feed once it has failed max_failures times in a row;
+// a max_failures of 0 never disables it.
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (RecordFeedFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.NextFetchInSeconds,
+		arg.MaxFailures,
+		arg.DisabledReason,
+		arg.ID,
 	)
+	var i RecordFeedFailureRow
+	err := row.Scan(&i.ConsecutiveFailures, &i.DisabledAt)
 	return i, err
 }
 
-const markFeedFetched = `-- name: MarkFeedFetched :exec
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
 UPDATE feeds
 SET
     last_fetched_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => $1::int),
+    etag = $2,
+    last_modified = $3,
+    hinted_interval_seconds = $4,
+    consecutive_failures = 0,
+    disabled_at = NULL,
+    disabled_reason = NULL,
     updated_at = NOW()
+WHERE id = $5
+`
+
+type RecordFeedSuccessParams struct {
+	NextFetchInSeconds    int32
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	HintedIntervalSeconds sql.NullInt32
+	ID                    uuid.UUID
+}
+
+// A successful fetch also re-enables a disabled feed, as happens when
+// one is fetched explicitly with agg --feed.
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess,
+		arg.NextFetchInSeconds,
+		arg.Etag,
+		arg.LastModified,
+		arg.HintedIntervalSeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const releaseFeedClaim = `-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_until = NULL
 WHERE id = $1
 `
 
-func (q *Queries) MarkFeedFetched(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, markFeedFetched, id)
+func (q *Queries) ReleaseFeedClaim(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedClaim, id)
+	return err
+}
+
+const renameFeed = `-- name: RenameFeed :exec
+UPDATE feeds
+SET
+    name = $2,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type RenameFeedParams struct {
+	ID   uuid.UUID
+	Name string
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) error {
+	_, err := q.db.ExecContext(ctx, renameFeed, arg.ID, arg.Name)
+	return err
+}
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET
+    fetch_interval_seconds = $2,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedIntervalParams struct {
+	ID                   uuid.UUID
+	FetchIntervalSeconds sql.NullInt32
+}
+
+// Clearing next_fetch_at makes the feed due immediately, so the new
+// interval takes effect from its next fetch.
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval, arg.ID, arg.FetchIntervalSeconds)
+	return err
+}
+
+const setFeedURL = `-- name: SetFeedURL :exec
+UPDATE feeds
+SET
+    url = $2,
+    etag = NULL,
+    last_modified = NULL,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedURLParams struct {
+	ID  uuid.UUID
+	Url string
+}
+
+// Moves a feed to a new URL, keeping its posts and follows. The cache
+// headers belong to the old URL, so they are dropped and the feed is
+// fetched again on the next run.
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedURL, arg.ID, arg.Url)
 	return err
 }
@@ -12,13 +12,44 @@ import (
 )
 
 type Feed struct {
-	ID            uuid.UUID
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        uuid.UUID
-	LastFetchedAt sql.NullTime
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ClaimedUntil          sql.NullTime
+	LastError             sql.NullString
+	LastErrorAt           sql.NullTime
+	ConsecutiveFailures   int32
+	DisabledAt            sql.NullTime
+	NextFetchAt           sql.NullTime
+	FetchIntervalSeconds  sql.NullInt32
+	HintedIntervalSeconds sql.NullInt32
+	DisabledReason        sql.NullString
+}
+
+type FeedAlias struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+}
+
+type FeedFetch struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Items      int32
+	Inserted   int32
+	Updated    int32
+	Duplicates int32
+	Failed     int32
+	Error      sql.NullString
 }
 
 type FeedFollow struct {
@@ -30,14 +61,48 @@ type FeedFollow struct {
 }
 
 type Post struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  sql.NullTime
+	FeedID       uuid.UUID
+	Guid         string
+	ContentHash  sql.NullString
+	SearchVector interface{}
+	Authors      sql.NullString
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostRevision struct {
+	ID          uuid.UUID
+	PostID      uuid.UUID
+	CreatedAt   time.Time
+	Title       string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	ContentHash string
+}
+
+type SavedPost struct {
 	ID          uuid.UUID
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	PostID      uuid.NullUUID
+	Note        sql.NullString
 	Title       string
 	Url         string
 	Description sql.NullString
 	PublishedAt sql.NullTime
-	FeedID      uuid.UUID
+	FeedName    string
 }
 
 type User struct {
@@ -11,48 +11,115 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET
+    guid = $1,
+    updated_at = NOW()
+WHERE posts.feed_id = $2
+  AND posts.guid = $3
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS adopted
+      WHERE adopted.feed_id = $2
+        AND adopted.guid = $1
+  )
+`
+
+type AdoptLegacyPostParams struct {
+	Guid         string
+	FeedID       uuid.UUID
+	FallbackGuid string
+}
+
+// Posts saved before GUIDs were tracked carry the fallback identity. When
+// their item shows up with a real GUID, switch the row over instead of
+// inserting the story a second time.
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.FallbackGuid)
+	return err
+}
+
+const createPostRevision = `-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (
     id,
+    post_id,
     created_at,
-    updated_at,
     title,
-    url,
     description,
     published_at,
-    feed_id
+    content_hash
 )
-VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
-)
-ON CONFLICT (url) DO NOTHING
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
+SELECT
+    $1::uuid,
+    posts.id,
+    NOW(),
+    posts.title,
+    posts.description,
+    posts.published_at,
+    posts.content_hash
+FROM posts
+WHERE posts.feed_id = $2
+  AND posts.guid = $3
+  AND posts.content_hash <> $4::text
 `
 
-type CreatePostParams struct {
+type CreatePostRevisionParams struct {
 	ID          uuid.UUID
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt sql.NullTime
 	FeedID      uuid.UUID
+	Guid        string
+	ContentHash string
 }
 
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
+// Keeps the stored version of a post before UpsertPost overwrites it with
+// changed content. Does nothing if the post is new or unchanged.
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRevision,
 		arg.ID,
-		arg.CreatedAt,
-		arg.UpdatedAt,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
 		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
 	)
+	return err
+}
+
+const getFeedPostingStats = `-- name: GetFeedPostingStats :one
+SELECT
+    COALESCE(
+        EXTRACT(EPOCH FROM MAX(recent.published_at) - MIN(recent.published_at))
+            / NULLIF(COUNT(*) - 1, 0),
+        0
+    )::int AS average_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = $1
+      AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent
+`
+
+// Average gap between the feed's most recent posts, used to adapt how
+// often it is polled. 0 when there are fewer than two dated posts.
+func (q *Queries) GetFeedPostingStats(ctx context.Context, feedID uuid.UUID) (int32, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPostingStats, feedID)
+	var average_gap_seconds int32
+	err := row.Scan(&average_gap_seconds)
+	return average_gap_seconds, err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, search_vector, authors
+FROM posts
+WHERE id = $1
+`
+
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
 	var i Post
 	err := row.Scan(
 		&i.ID,
@@ -63,35 +130,167 @@ func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, e
 		&i.Description,
 		&i.PublishedAt,
 		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.SearchVector,
+		&i.Authors,
 	)
 	return i, err
 }
 
+const getPostRevisions = `-- name: GetPostRevisions :many
+SELECT id, post_id, created_at, title, description, published_at, content_hash
+FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]PostRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getPostRevisions, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRevision
+	for rows.Next() {
+		var i PostRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.CreatedAt,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT 
-    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id
-FROM posts
-JOIN feeds ON posts.feed_id = feeds.id
-JOIN feed_follows ON feed_follows.feed_id = feeds.id
-WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2
+WITH user_posts AS (
+    SELECT
+        posts.id,
+        posts.created_at,
+        posts.updated_at,
+        posts.title,
+        posts.url,
+        posts.description,
+        posts.published_at,
+        posts.feed_id,
+        posts.authors,
+        feeds.name AS feed_name,
+        post_reads.read_at,
+        CASE
+            WHEN $2::text = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at)
+        END::timestamp AS sort_time
+    FROM posts
+    JOIN feeds ON posts.feed_id = feeds.id
+    JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    LEFT JOIN post_reads
+        ON post_reads.post_id = posts.id
+       AND post_reads.user_id = feed_follows.user_id
+    WHERE feed_follows.user_id = $7
+      AND (NOT $8::bool OR post_reads.read_at IS NULL)
+      AND (
+          cardinality($9::text[]) = 0
+          OR feeds.name = ANY($9::text[])
+          OR feeds.url = ANY($9::text[])
+      )
+      AND ($10::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $10)
+      AND ($11::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $11)
+)
+SELECT id, created_at, updated_at, title, url, description, published_at, feed_id, authors, feed_name, read_at, sort_time
+FROM user_posts
+WHERE $1::uuid IS NULL
+   OR (
+       $2::text = 'feed'
+       AND (
+           feed_name > $3::text
+           OR (
+               feed_name = $3::text
+               AND (sort_time, id) < ($4::timestamp, $1::uuid)
+           )
+       )
+   )
+   OR (
+       $2::text <> 'feed'
+       AND (sort_time, id) < ($4::timestamp, $1::uuid)
+   )
+ORDER BY
+    CASE WHEN $2::text = 'feed' THEN feed_name END ASC,
+    sort_time DESC,
+    id DESC
+OFFSET $5
+LIMIT $6
 `
 
 type GetPostsForUserParams struct {
-	UserID uuid.UUID
-	Limit  int32
+	AfterID    uuid.NullUUID
+	Sort       string
+	AfterFeed  sql.NullString
+	AfterTime  sql.NullTime
+	Offset     int32
+	Limit      int32
+	UserID     uuid.UUID
+	UnreadOnly bool
+	Feeds      []string
+	Since      sql.NullTime
+	Until      sql.NullTime
 }
 
-func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]Post, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.UserID, arg.Limit)
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Authors     sql.NullString
+	FeedName    string
+	ReadAt      sql.NullTime
+	SortTime    time.Time
+}
+
+// Lists the posts of the feeds a user follows. sort is 'published'
+// (newest first, undated posts by when they were fetched), 'fetched'
+// (newest fetched first) or 'feed' (by feed name, then as 'published').
+// sort_time is the timestamp a row is ordered by; together with feed_name
+// and id it forms the keyset cursor, given as the after_* arguments.
+// An empty feeds array means all followed feeds.
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.AfterID,
+		arg.Sort,
+		arg.AfterFeed,
+		arg.AfterTime,
+		arg.Offset,
+		arg.Limit,
+		arg.UserID,
+		arg.UnreadOnly,
+		pq.Array(arg.Feeds),
+		arg.Since,
+		arg.Until,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Post
+	var items []GetPostsForUserRow
 	for rows.Next() {
-		var i Post
+		var i GetPostsForUserRow
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
@@ -101,6 +300,10 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Authors,
+			&i.FeedName,
+			&i.ReadAt,
+			&i.SortTime,
 		); err != nil {
 			return nil, err
 		}
@@ -114,3 +317,225 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	}
 	return items, nil
 }
+
+const markAllPostsRead = `-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+func (q *Queries) MarkAllPostsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllPostsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1
+  AND post_id = $2
+`
+
+type MarkPostUnreadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    posts.created_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, websearch_to_tsquery('english', $1::text)) AS rank,
+    ts_headline(
+        'english',
+        regexp_replace(coalesce(posts.description, posts.title), '<[^>]*>', ' ', 'g'),
+        websearch_to_tsquery('english', $1::text),
+        'StartSel=**, StopSel=**, MaxFragments=2, MinWords=5, MaxWords=20'
+    )::text AS snippet
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $2
+  AND posts.search_vector @@ websearch_to_tsquery('english', $1::text)
+  AND ($3::text IS NULL OR feeds.name = $3 OR feeds.url = $3)
+  AND ($4::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= $4)
+  AND ($5::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < $5)
+ORDER BY rank DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT $6
+`
+
+type SearchPostsForUserParams struct {
+	Query  string
+	UserID uuid.UUID
+	Feed   sql.NullString
+	Since  sql.NullTime
+	Until  sql.NullTime
+	Limit  int32
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+// Full-text search over the posts of the feeds a user follows. feed
+// matches either a feed's name or its URL; feed, since and until are
+// optional. Undated posts are filtered by when they were fetched.
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPost = `-- name: UpsertPost :one
+WITH upserted AS (
+    INSERT INTO posts (
+        id,
+        created_at,
+        updated_at,
+        title,
+        url,
+        description,
+        published_at,
+        feed_id,
+        guid,
+        content_hash,
+        authors
+    )
+    VALUES (
+        $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+    )
+    ON CONFLICT (feed_id, guid) DO UPDATE
+    SET
+        title = EXCLUDED.title,
+        description = EXCLUDED.description,
+        published_at = EXCLUDED.published_at,
+        content_hash = EXCLUDED.content_hash,
+        authors = EXCLUDED.authors,
+        updated_at = CASE
+            WHEN posts.content_hash IS NULL THEN posts.updated_at
+            ELSE EXCLUDED.updated_at
+        END
+    WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+    RETURNING id
+)
+SELECT
+    COUNT(*) FILTER (WHERE id = $1) AS inserted,
+    COUNT(*) FILTER (WHERE id <> $1) AS updated
+FROM upserted
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Authors     sql.NullString
+}
+
+type UpsertPostRow struct {
+	Inserted int64
+	Updated  int64
+}
+
+// Inserts a new post, or refreshes a known one whose content has changed.
+// Always returns one row counting what happened: inserted = 1 for a new
+// post, updated = 1 for a changed one, both 0 when the stored post is
+// already up to date. Rows saved before content hashes existed only have
+// their hash filled in.
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Authors,
+	)
+	var i UpsertPostRow
+	err := row.Scan(&i.Inserted, &i.Updated)
+	return i, err
+}
@@ -0,0 +1,872 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: feeds.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const addFeedAlias = `-- name: AddFeedAlias :exec
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES (?1, datetime('now'), ?2)
+ON CONFLICT (url) DO UPDATE
+SET feed_id = excluded.feed_id
+`
+
+type AddFeedAliasParams struct {
+	Url    string
+	FeedID uuid.UUID
+}
+
+// Keeps url pointing at a feed that has moved away from it.
+func (q *Queries) AddFeedAlias(ctx context.Context, arg AddFeedAliasParams) error {
+	_, err := q.db.ExecContext(ctx, addFeedAlias, arg.Url, arg.FeedID)
+	return err
+}
+
+const claimFeed = `-- name: ClaimFeed :one
+UPDATE feeds
+SET
+    updated_at = datetime('now'),
+    claimed_until = datetime('now', '+' || CAST(?1 AS INTEGER) || ' seconds')
+WHERE id = ?2
+  AND (claimed_until IS NULL OR claimed_until < datetime('now'))
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds
+`
+
+type ClaimFeedParams struct {
+	LeaseSeconds int64
+	ID           uuid.UUID
+}
+
+type ClaimFeedRow struct {
+	ID                    uuid.UUID
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ConsecutiveFailures   int64
+	FetchIntervalSeconds  sql.NullInt64
+	HintedIntervalSeconds sql.NullInt64
+}
+
+// Claims a single feed regardless of its schedule or disabled state, for
+// explicit refreshes. Fails with no rows if another process holds it.
+func (q *Queries) ClaimFeed(ctx context.Context, arg ClaimFeedParams) (ClaimFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, claimFeed, arg.LeaseSeconds, arg.ID)
+	var i ClaimFeedRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ConsecutiveFailures,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+	)
+	return i, err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET
+    updated_at = datetime('now'),
+    claimed_until = datetime('now', '+' || CAST(?1 AS INTEGER) || ' seconds')
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (claimed_until IS NULL OR claimed_until < datetime('now'))
+      AND (next_fetch_at IS NULL OR next_fetch_at <= datetime('now'))
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT ?2
+)
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds
+`
+
+type ClaimFeedsToFetchParams struct {
+	LeaseSeconds int64
+	BatchSize    int64
+}
+
+type ClaimFeedsToFetchRow struct {
+	ID                    uuid.UUID
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ConsecutiveFailures   int64
+	FetchIntervalSeconds  sql.NullInt64
+	HintedIntervalSeconds sql.NullInt64
+}
+
+// Leases a batch of due feeds in one statement. SQLite runs one writer
+// at a time, so concurrent agg processes never pick the same feed.
+// Feeds whose lease has run out can be claimed again. Disabled feeds are
+// skipped.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, arg ClaimFeedsToFetchParams) ([]ClaimFeedsToFetchRow, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, arg.LeaseSeconds, arg.BatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ClaimFeedsToFetchRow
+	for rows.Next() {
+		var i ClaimFeedsToFetchRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.ConsecutiveFailures,
+			&i.FetchIntervalSeconds,
+			&i.HintedIntervalSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const countFeedDependents = `-- name: CountFeedDependents :one
+SELECT
+    (SELECT COUNT(*) FROM posts WHERE posts.feed_id = ?1) AS post_count,
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = ?1) AS follow_count
+`
+
+type CountFeedDependentsRow struct {
+	PostCount   int64
+	FollowCount int64
+}
+
+func (q *Queries) CountFeedDependents(ctx context.Context, feedID uuid.UUID) (CountFeedDependentsRow, error) {
+	row := q.db.QueryRowContext(ctx, countFeedDependents, feedID)
+	var i CountFeedDependentsRow
+	err := row.Scan(&i.PostCount, &i.FollowCount)
+	return i, err
+}
+
+const createFeed = `-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, fetch_interval_seconds)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, claimed_until, last_error, last_error_at, consecutive_failures, disabled_at, disabled_reason, next_fetch_at, fetch_interval_seconds, hinted_interval_seconds
+`
+
+type CreateFeedParams struct {
+	ID                   uuid.UUID
+	CreatedAt            time.Time
+	UpdatedAt            time.Time
+	Name                 string
+	Url                  string
+	UserID               uuid.UUID
+	FetchIntervalSeconds sql.NullInt64
+}
+
+func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, createFeed,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.FetchIntervalSeconds,
+	)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.ClaimedUntil,
+		&i.LastError,
+		&i.LastErrorAt,
+		&i.ConsecutiveFailures,
+		&i.DisabledAt,
+		&i.DisabledReason,
+		&i.NextFetchAt,
+		&i.FetchIntervalSeconds,
+		&i.HintedIntervalSeconds,
+	)
+	return i, err
+}
+
+const createFeedFetch = `-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+    id,
+    feed_id,
+    started_at,
+    finished_at,
+    status,
+    items,
+    inserted,
+    updated,
+    duplicates,
+    failed,
+    error
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type CreateFeedFetchParams struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Items      int64
+	Inserted   int64
+	Updated    int64
+	Duplicates int64
+	Failed     int64
+	Error      sql.NullString
+}
+
+func (q *Queries) CreateFeedFetch(ctx context.Context, arg CreateFeedFetchParams) error {
+	_, err := q.db.ExecContext(ctx, createFeedFetch,
+		arg.ID,
+		arg.FeedID,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.Status,
+		arg.Items,
+		arg.Inserted,
+		arg.Updated,
+		arg.Duplicates,
+		arg.Failed,
+		arg.Error,
+	)
+	return err
+}
+
+const createFeedFollow = `-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING id, created_at, updated_at, user_id, feed_id
+`
+
+type CreateFeedFollowParams struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+}
+
+func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, createFeedFollow,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.UserID,
+		arg.FeedID,
+	)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+	)
+	return i, err
+}
+
+const deleteFeed = `-- name: DeleteFeed :execrows
+DELETE FROM feeds
+WHERE id = ?
+  AND user_id = ?
+`
+
+type DeleteFeedParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+}
+
+// Deletes a feed owned by user_id. Its posts, follows and fetch history
+// go with it; saved copies of its posts are kept.
+func (q *Queries) DeleteFeed(ctx context.Context, arg DeleteFeedParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeed, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteFeedAlias = `-- name: DeleteFeedAlias :exec
+DELETE FROM feed_aliases
+WHERE feed_aliases.url = ?1
+  AND feed_aliases.feed_id = ?2
+`
+
+type DeleteFeedAliasParams struct {
+	Url    string
+	FeedID uuid.UUID
+}
+
+// Drops the alias for a URL the feed has moved back to.
+func (q *Queries) DeleteFeedAlias(ctx context.Context, arg DeleteFeedAliasParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedAlias, arg.Url, arg.FeedID)
+	return err
+}
+
+const deleteFeedFollow = `-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows
+WHERE user_id = ?
+  AND feed_id = ?
+`
+
+type DeleteFeedFollowParams struct {
+	UserID uuid.UUID
+	FeedID uuid.UUID
+}
+
+func (q *Queries) DeleteFeedFollow(ctx context.Context, arg DeleteFeedFollowParams) error {
+	_, err := q.db.ExecContext(ctx, deleteFeedFollow, arg.UserID, arg.FeedID)
+	return err
+}
+
+const enableFeed = `-- name: EnableFeed :exec
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = ?
+`
+
+// Clears the feed's failures and makes it due right away, rather than
+// at the retry time its last failure set.
+func (q *Queries) EnableFeed(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, id)
+	return err
+}
+
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT feeds.id, feeds.name, feeds.url, feeds.created_at, feeds.updated_at, feeds.user_id
+FROM feeds
+JOIN (SELECT CAST(?1 AS TEXT) AS url) AS lookup
+WHERE feeds.url = lookup.url
+   OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = lookup.url)
+ORDER BY feeds.url = lookup.url DESC
+LIMIT 1
+`
+
+type GetFeedByURLRow struct {
+	ID        uuid.UUID
+	Name      string
+	Url       string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+}
+
+// Also finds feeds that have moved away from url. A feed currently at
+// url wins over one that used to be there. url is joined in as a column
+// because sqlc can't bind arguments in ORDER BY.
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (GetFeedByURLRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
+	var i GetFeedByURLRow
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Url,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+	)
+	return i, err
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT
+    feed_follows.id,
+    feed_follows.created_at,
+    feed_follows.updated_at,
+    feed_follows.user_id,
+    feed_follows.feed_id,
+    users.name AS user_name,
+    feeds.name AS feed_name
+FROM feed_follows
+JOIN users ON users.id = feed_follows.user_id
+JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.id = ?
+`
+
+type GetFeedFollowRow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+	UserName  string
+	FeedName  string
+}
+
+// SQLite has no INSERT in WITH, so the names that CreateFeedFollow
+// returns on PostgreSQL are looked up separately.
+func (q *Queries) GetFeedFollow(ctx context.Context, id uuid.UUID) (GetFeedFollowRow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, id)
+	var i GetFeedFollowRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+		&i.UserName,
+		&i.FeedName,
+	)
+	return i, err
+}
+
+const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
+SELECT
+    feed_follows.id,
+    feed_follows.created_at,
+    feed_follows.updated_at,
+    feed_follows.user_id,
+    feed_follows.feed_id,
+    users.name AS user_name,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    (
+        SELECT COUNT(*)
+        FROM posts
+        WHERE posts.feed_id = feed_follows.feed_id
+          AND NOT EXISTS (
+              SELECT 1
+              FROM post_reads
+              WHERE post_reads.post_id = posts.id
+                AND post_reads.user_id = feed_follows.user_id
+          )
+    ) AS unread_count
+FROM feed_follows
+JOIN users ON users.id = feed_follows.user_id
+JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = ?
+ORDER BY feed_follows.created_at DESC
+`
+
+type GetFeedFollowsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	FeedID      uuid.UUID
+	UserName    string
+	FeedName    string
+	FeedUrl     string
+	UnreadCount int64
+}
+
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFollowsForUserRow
+	for rows.Next() {
+		var i GetFeedFollowsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.UserName,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UnreadCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeeds = `-- name: GetFeeds :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.created_at,
+    feeds.last_fetched_at,
+    feeds.next_fetch_at,
+    feeds.disabled_at
+FROM feeds
+JOIN users ON feeds.user_id = users.id
+ORDER BY feeds.created_at
+`
+
+type GetFeedsRow struct {
+	FeedID        uuid.UUID
+	FeedName      string
+	FeedUrl       string
+	UserName      string
+	CreatedAt     time.Time
+	LastFetchedAt sql.NullTime
+	NextFetchAt   sql.NullTime
+	DisabledAt    sql.NullTime
+}
+
+func (q *Queries) GetFeeds(ctx context.Context) ([]GetFeedsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsRow
+	for rows.Next() {
+		var i GetFeedsRow
+		if err := rows.Scan(
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+			&i.CreatedAt,
+			&i.LastFetchedAt,
+			&i.NextFetchAt,
+			&i.DisabledAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedsWithErrors = `-- name: GetFeedsWithErrors :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.last_error,
+    feeds.last_error_at,
+    feeds.consecutive_failures,
+    feeds.disabled_at,
+    feeds.disabled_reason
+FROM feeds
+JOIN users ON feeds.user_id = users.id
+WHERE feeds.consecutive_failures > 0
+   OR feeds.disabled_at IS NOT NULL
+ORDER BY feeds.disabled_at ASC NULLS LAST, feeds.consecutive_failures DESC
+`
+
+type GetFeedsWithErrorsRow struct {
+	FeedID              uuid.UUID
+	FeedName            string
+	FeedUrl             string
+	UserName            string
+	LastError           sql.NullString
+	LastErrorAt         sql.NullTime
+	ConsecutiveFailures int64
+	DisabledAt          sql.NullTime
+	DisabledReason      sql.NullString
+}
+
+func (q *Queries) GetFeedsWithErrors(ctx context.Context) ([]GetFeedsWithErrorsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedsWithErrors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedsWithErrorsRow
+	for rows.Next() {
+		var i GetFeedsWithErrorsRow
+		if err := rows.Scan(
+			&i.FeedID,
+			&i.FeedName,
+			&i.FeedUrl,
+			&i.UserName,
+			&i.LastError,
+			&i.LastErrorAt,
+			&i.ConsecutiveFailures,
+			&i.DisabledAt,
+			&i.DisabledReason,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveFeedURL = `-- name: MoveFeedURL :execrows
+UPDATE feeds
+SET
+    url = ?1,
+    updated_at = datetime('now')
+WHERE feeds.id = ?2
+  AND feeds.url = ?3
+  AND NOT EXISTS (
+      SELECT 1
+      FROM feeds AS other
+      WHERE other.url = ?1
+  )
+`
+
+type MoveFeedURLParams struct {
+	NewUrl string
+	ID     uuid.UUID
+	OldUrl string
+}
+
+// Moves a feed that redirected permanently from old_url to new_url.
+// Nothing happens if another feed is already at new_url. SQLite has no
+// DML in WITH, so the caller updates the aliases with DeleteFeedAlias
+// and AddFeedAlias in the same transaction.
+func (q *Queries) MoveFeedURL(ctx context.Context, arg MoveFeedURLParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, moveFeedURL, arg.NewUrl, arg.ID, arg.OldUrl)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
+UPDATE feeds
+SET
+    last_error = CAST(?1 AS TEXT),
+    last_error_at = datetime('now'),
+    next_fetch_at = datetime('now', '+' || CAST(?2 AS INTEGER) || ' seconds'),
+    consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE
+        WHEN CAST(?3 AS INTEGER) > 0
+             AND consecutive_failures + 1 >= CAST(?3 AS INTEGER)
+        THEN datetime('now')
+        ELSE disabled_at
+    END,
+    disabled_reason = CASE
+        WHEN CAST(?3 AS INTEGER) > 0
+             AND consecutive_failures + 1 >= CAST(?3 AS INTEGER)
+        THEN CAST(?4 AS TEXT)
+        ELSE disabled_reason
+    END,
+    updated_at = datetime('now')
+WHERE id = ?5
+RETURNING consecutive_failures, disabled_at
+`
+
+type RecordFeedFailureParams struct {
+	LastError          string
+	NextFetchInSeconds int64
+	MaxFailures        int64
+	DisabledReason     string
+	ID                 uuid.UUID
+}
+
+type RecordFeedFailureRow struct {
+	ConsecutiveFailures int64
+	DisabledAt          sql.NullTime
+}
+
+// Disables the feed once it has failed max_failures times in a row;
+// a max_failures of 0 never disables it.
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (RecordFeedFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.NextFetchInSeconds,
+		arg.MaxFailures,
+		arg.DisabledReason,
+		arg.ID,
+	)
+	var i RecordFeedFailureRow
+	err := row.Scan(&i.ConsecutiveFailures, &i.DisabledAt)
+	return i, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET
+    last_fetched_at = datetime('now'),
+    next_fetch_at = datetime('now', '+' || CAST(?1 AS INTEGER) || ' seconds'),
+    etag = ?2,
+    last_modified = ?3,
+    hinted_interval_seconds = ?4,
+    consecutive_failures = 0,
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    updated_at = datetime('now')
+WHERE id = ?5
+`
+
+type RecordFeedSuccessParams struct {
+	NextFetchInSeconds    int64
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	HintedIntervalSeconds sql.NullInt64
+	ID                    uuid.UUID
+}
+
+// A successful fetch also re-enables a disabled feed, as happens when
+// one is fetched explicitly with agg --feed.
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess,
+		arg.NextFetchInSeconds,
+		arg.Etag,
+		arg.LastModified,
+		arg.HintedIntervalSeconds,
+		arg.ID,
+	)
+	return err
+}
+
+const releaseFeedClaim = `-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_until = NULL
+WHERE id = ?
+`
+
+func (q *Queries) ReleaseFeedClaim(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, releaseFeedClaim, id)
+	return err
+}
+
+const renameFeed = `-- name: RenameFeed :exec
+UPDATE feeds
+SET
+    name = ?1,
+    updated_at = datetime('now')
+WHERE id = ?2
+`
+
+type RenameFeedParams struct {
+	Name string
+	ID   uuid.UUID
+}
+
+func (q *Queries) RenameFeed(ctx context.Context, arg RenameFeedParams) error {
+	_, err := q.db.ExecContext(ctx, renameFeed, arg.Name, arg.ID)
+	return err
+}
+
+const setFeedInterval = `-- name: SetFeedInterval :exec
+UPDATE feeds
+SET
+    fetch_interval_seconds = ?1,
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = ?2
+`
+
+type SetFeedIntervalParams struct {
+	FetchIntervalSeconds sql.NullInt64
+	ID                   uuid.UUID
+}
+
+// Clearing next_fetch_at makes the feed due immediately, so the new
+// interval takes effect from its next fetch.
+func (q *Queries) SetFeedInterval(ctx context.Context, arg SetFeedIntervalParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedInterval, arg.FetchIntervalSeconds, arg.ID)
+	return err
+}
+
+const setFeedURL = `-- name: SetFeedURL :exec
+UPDATE feeds
+SET
+    url = ?1,
+    etag = NULL,
+    last_modified = NULL,
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = ?2
+`
+
+type SetFeedURLParams struct {
+	Url string
+	ID  uuid.UUID
+}
+
+// Moves a feed to a new URL, keeping its posts and follows. The cache
+// headers belong to the old URL, so they are dropped and the feed is
+// fetched again on the next run.
+func (q *Queries) SetFeedURL(ctx context.Context, arg SetFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedURL, arg.Url, arg.ID)
+	return err
+}
@@ -0,0 +1,118 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+
+package sqlitedb
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Feed struct {
+	ID                    uuid.UUID
+	CreatedAt             time.Time
+	UpdatedAt             time.Time
+	Name                  string
+	Url                   string
+	UserID                uuid.UUID
+	LastFetchedAt         sql.NullTime
+	Etag                  sql.NullString
+	LastModified          sql.NullString
+	ClaimedUntil          sql.NullTime
+	LastError             sql.NullString
+	LastErrorAt           sql.NullTime
+	ConsecutiveFailures   int64
+	DisabledAt            sql.NullTime
+	DisabledReason        sql.NullString
+	NextFetchAt           sql.NullTime
+	FetchIntervalSeconds  sql.NullInt64
+	HintedIntervalSeconds sql.NullInt64
+}
+
+type FeedAlias struct {
+	Url       string
+	CreatedAt time.Time
+	FeedID    uuid.UUID
+}
+
+type FeedFetch struct {
+	ID         uuid.UUID
+	FeedID     uuid.UUID
+	StartedAt  time.Time
+	FinishedAt time.Time
+	Status     string
+	Items      int64
+	Inserted   int64
+	Updated    int64
+	Duplicates int64
+	Failed     int64
+	Error      sql.NullString
+}
+
+type FeedFollow struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    uuid.UUID
+	FeedID    uuid.UUID
+}
+
+type Post struct {
+	Seq         int64
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Authors     sql.NullString
+}
+
+type PostRead struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+	ReadAt time.Time
+}
+
+type PostRevision struct {
+	ID          uuid.UUID
+	PostID      uuid.UUID
+	CreatedAt   time.Time
+	Title       string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	ContentHash string
+}
+
+type PostsFt struct {
+	Title       string
+	Description string
+}
+
+type SavedPost struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      uuid.UUID
+	PostID      uuid.NullUUID
+	Note        sql.NullString
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedName    string
+}
+
+type User struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Name      string
+}
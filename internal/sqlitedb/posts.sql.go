@@ -0,0 +1,520 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: posts.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET
+    guid = ?1,
+    updated_at = datetime('now')
+WHERE posts.feed_id = ?2
+  AND posts.guid = ?3
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS adopted
+      WHERE adopted.feed_id = ?2
+        AND adopted.guid = ?1
+  )
+`
+
+type AdoptLegacyPostParams struct {
+	Guid         string
+	FeedID       uuid.UUID
+	FallbackGuid string
+}
+
+// Posts saved before GUIDs were tracked carry the fallback identity. When
+// their item shows up with a real GUID, switch the row over instead of
+// inserting the story a second time.
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.FallbackGuid)
+	return err
+}
+
+const createPostRevision = `-- name: CreatePostRevision :exec
+INSERT INTO post_revisions (
+    id,
+    post_id,
+    created_at,
+    title,
+    description,
+    published_at,
+    content_hash
+)
+SELECT
+    ?1,
+    posts.id,
+    datetime('now'),
+    posts.title,
+    posts.description,
+    posts.published_at,
+    posts.content_hash
+FROM posts
+WHERE posts.feed_id = ?2
+  AND posts.guid = ?3
+  AND posts.content_hash <> CAST(?4 AS TEXT)
+`
+
+type CreatePostRevisionParams struct {
+	ID          uuid.UUID
+	FeedID      uuid.UUID
+	Guid        string
+	ContentHash string
+}
+
+// Keeps the stored version of a post before UpsertPost overwrites it with
+// changed content. Does nothing if the post is new or unchanged.
+func (q *Queries) CreatePostRevision(ctx context.Context, arg CreatePostRevisionParams) error {
+	_, err := q.db.ExecContext(ctx, createPostRevision,
+		arg.ID,
+		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+	)
+	return err
+}
+
+const getFeedPostingStats = `-- name: GetFeedPostingStats :one
+SELECT
+    CAST(COALESCE(
+        (julianday(MAX(recent.published_at)) - julianday(MIN(recent.published_at))) * 86400
+            / NULLIF(COUNT(*) - 1, 0),
+        0
+    ) AS INTEGER) AS average_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = ?
+      AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent
+`
+
+// Average gap between the feed's most recent posts, used to adapt how
+// often it is polled. 0 when there are fewer than two dated posts.
+func (q *Queries) GetFeedPostingStats(ctx context.Context, feedID uuid.UUID) (int64, error) {
+	row := q.db.QueryRowContext(ctx, getFeedPostingStats, feedID)
+	var average_gap_seconds int64
+	err := row.Scan(&average_gap_seconds)
+	return average_gap_seconds, err
+}
+
+const getPost = `-- name: GetPost :one
+SELECT seq, id, created_at, updated_at, title, url, description, published_at, feed_id, guid, content_hash, authors
+FROM posts
+WHERE id = ?
+`
+
+func (q *Queries) GetPost(ctx context.Context, id uuid.UUID) (Post, error) {
+	row := q.db.QueryRowContext(ctx, getPost, id)
+	var i Post
+	err := row.Scan(
+		&i.Seq,
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.ContentHash,
+		&i.Authors,
+	)
+	return i, err
+}
+
+const getPostRevisions = `-- name: GetPostRevisions :many
+SELECT id, post_id, created_at, title, description, published_at, content_hash
+FROM post_revisions
+WHERE post_id = ?
+ORDER BY created_at ASC
+`
+
+func (q *Queries) GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]PostRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getPostRevisions, postID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PostRevision
+	for rows.Next() {
+		var i PostRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.PostID,
+			&i.CreatedAt,
+			&i.Title,
+			&i.Description,
+			&i.PublishedAt,
+			&i.ContentHash,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT
+    posts.id,
+    posts.created_at,
+    posts.updated_at,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    posts.feed_id,
+    posts.authors,
+    feeds.name AS feed_name,
+    post_reads.read_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+JOIN (SELECT CAST(?1 AS TEXT) AS sort) AS options
+LEFT JOIN post_reads
+    ON post_reads.post_id = posts.id
+   AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = ?2
+  AND (NOT CAST(?3 AS BOOLEAN) OR post_reads.read_at IS NULL)
+  AND (
+      json_array_length(CAST(?4 AS TEXT)) = 0
+      OR feeds.name IN (SELECT value FROM json_each(?4))
+      OR feeds.url IN (SELECT value FROM json_each(?4))
+  )
+  AND (?5 IS NULL OR COALESCE(posts.published_at, posts.created_at) >= ?5)
+  AND (?6 IS NULL OR COALESCE(posts.published_at, posts.created_at) < ?6)
+  AND (
+      ?7 IS NULL
+      OR (
+          options.sort = 'feed'
+          AND (
+              feeds.name > ?8
+              OR (
+                  feeds.name = ?8
+                  AND (COALESCE(posts.published_at, posts.created_at), posts.id)
+                      < (?9, ?7)
+              )
+          )
+      )
+      OR (
+          options.sort = 'fetched'
+          AND (posts.created_at, posts.id) < (?9, ?7)
+      )
+      OR (
+          options.sort = 'published'
+          AND (COALESCE(posts.published_at, posts.created_at), posts.id)
+              < (?9, ?7)
+      )
+  )
+ORDER BY
+    CASE WHEN options.sort = 'feed' THEN feeds.name END ASC,
+    CASE WHEN options.sort = 'fetched' THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END DESC,
+    posts.id DESC
+LIMIT ?11
+OFFSET ?10
+`
+
+type GetPostsForUserParams struct {
+	Sort       string
+	UserID     uuid.UUID
+	UnreadOnly bool
+	Feeds      string
+	Since      interface{}
+	Until      interface{}
+	AfterID    interface{}
+	AfterFeed  sql.NullString
+	AfterTime  sql.NullTime
+	Offset     int64
+	Limit      int64
+}
+
+type GetPostsForUserRow struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Authors     sql.NullString
+	FeedName    string
+	ReadAt      sql.NullTime
+}
+
+// Lists the posts of the feeds a user follows, like its PostgreSQL
+// counterpart. feeds is a JSON array of feed names or URLs; an empty
+// array means all followed feeds. sort is joined in as a column because
+// sqlc can't bind arguments in ORDER BY. The sort time is not returned,
+// since SQLite would hand it back untyped; callers derive it from the row.
+func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.Sort,
+		arg.UserID,
+		arg.UnreadOnly,
+		arg.Feeds,
+		arg.Since,
+		arg.Until,
+		arg.AfterID,
+		arg.AfterFeed,
+		arg.AfterTime,
+		arg.Offset,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsForUserRow
+	for rows.Next() {
+		var i GetPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Authors,
+			&i.FeedName,
+			&i.ReadAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markAllPostsRead = `-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, datetime('now')
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+func (q *Queries) MarkAllPostsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markAllPostsRead, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES (?, ?, datetime('now'))
+ON CONFLICT (user_id, post_id) DO NOTHING
+`
+
+type MarkPostReadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = ?
+  AND post_id = ?
+`
+
+type MarkPostUnreadParams struct {
+	UserID uuid.UUID
+	PostID uuid.UUID
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchPostsForUser = `-- name: SearchPostsForUser :many
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    posts.created_at,
+    feeds.name AS feed_name,
+    CAST(-bm25(posts_fts, 10.0, 1.0) AS REAL) AS "rank",
+    CAST(snippet(posts_fts, -1, '**', '**', '...', 20) AS TEXT) AS snippet
+FROM posts_fts(CAST(?1 AS TEXT))
+JOIN posts ON posts.seq = posts_fts.rowid
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = ?2
+  AND (CAST(?3 AS TEXT) IS NULL OR feeds.name = ?3 OR feeds.url = ?3)
+  AND (?4 IS NULL OR COALESCE(posts.published_at, posts.created_at) >= ?4)
+  AND (?5 IS NULL OR COALESCE(posts.published_at, posts.created_at) < ?5)
+ORDER BY "rank" DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT ?6
+`
+
+type SearchPostsForUserParams struct {
+	Query  string
+	UserID uuid.UUID
+	Feed   sql.NullString
+	Since  interface{}
+	Until  interface{}
+	Limit  int64
+}
+
+type SearchPostsForUserRow struct {
+	ID          uuid.UUID
+	Title       string
+	Url         string
+	PublishedAt sql.NullTime
+	CreatedAt   time.Time
+	FeedName    string
+	Rank        float64
+	Snippet     string
+}
+
+// Full-text search over the posts of the feeds a user follows, using the
+// FTS5 index. query is in FTS5 syntax. Titles weigh more than
+// descriptions; rank is the negated bm25 score, so higher is better.
+func (q *Queries) SearchPostsForUser(ctx context.Context, arg SearchPostsForUserParams) ([]SearchPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPostsForUser,
+		arg.Query,
+		arg.UserID,
+		arg.Feed,
+		arg.Since,
+		arg.Until,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsForUserRow
+	for rows.Next() {
+		var i SearchPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.CreatedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (
+    id,
+    created_at,
+    updated_at,
+    title,
+    url,
+    description,
+    published_at,
+    feed_id,
+    guid,
+    content_hash,
+    authors
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET
+    title = excluded.title,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    content_hash = excluded.content_hash,
+    authors = excluded.authors,
+    updated_at = CASE
+        WHEN posts.content_hash IS NULL THEN posts.updated_at
+        ELSE excluded.updated_at
+    END
+WHERE posts.content_hash IS NOT excluded.content_hash
+RETURNING id
+`
+
+type UpsertPostParams struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt sql.NullTime
+	FeedID      uuid.UUID
+	Guid        string
+	ContentHash sql.NullString
+	Authors     sql.NullString
+}
+
+// Inserts a new post, or refreshes a known one whose content has changed,
+// and returns its id: the given id for a new post, the stored one for a
+// changed one. Returns no rows when the stored post is already up to
+// date. Rows saved before content hashes existed only have their hash
+// filled in.
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.ID,
+		arg.CreatedAt,
+		arg.UpdatedAt,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.ContentHash,
+		arg.Authors,
+	)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
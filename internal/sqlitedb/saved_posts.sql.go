@@ -0,0 +1,151 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.30.0
+// source: saved_posts.sql
+
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+)
+
+const createSavedPost = `-- name: CreateSavedPost :one
+INSERT INTO saved_posts (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    post_id,
+    note,
+    title,
+    url,
+    description,
+    published_at,
+    feed_name
+)
+SELECT
+    ?1,
+    datetime('now'),
+    datetime('now'),
+    ?2,
+    posts.id,
+    ?3,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    feeds.name
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id = ?4
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET
+    note = COALESCE(excluded.note, saved_posts.note),
+    updated_at = datetime('now')
+RETURNING id, created_at, updated_at, user_id, post_id, note, title, url, description, published_at, feed_name
+`
+
+type CreateSavedPostParams struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Note   sql.NullString
+	PostID uuid.UUID
+}
+
+// Saving an already saved post keeps it, replacing the note if a new one
+// is given.
+func (q *Queries) CreateSavedPost(ctx context.Context, arg CreateSavedPostParams) (SavedPost, error) {
+	row := q.db.QueryRowContext(ctx, createSavedPost,
+		arg.ID,
+		arg.UserID,
+		arg.Note,
+		arg.PostID,
+	)
+	var i SavedPost
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.PostID,
+		&i.Note,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedName,
+	)
+	return i, err
+}
+
+const deleteSavedPost = `-- name: DeleteSavedPost :execrows
+DELETE FROM saved_posts
+WHERE user_id = ?1
+  AND (post_id = ?2 OR id = ?2)
+`
+
+type DeleteSavedPostParams struct {
+	UserID uuid.UUID
+	ID     uuid.NullUUID
+}
+
+// Accepts either the post's id or, for posts that no longer exist, the
+// id of the saved copy.
+func (q *Queries) DeleteSavedPost(ctx context.Context, arg DeleteSavedPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteSavedPost, arg.UserID, arg.ID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getSavedPostsForUser = `-- name: GetSavedPostsForUser :many
+SELECT id, created_at, updated_at, user_id, post_id, note, title, url, description, published_at, feed_name
+FROM saved_posts
+WHERE user_id = ?
+ORDER BY created_at DESC
+LIMIT ?
+`
+
+type GetSavedPostsForUserParams struct {
+	UserID uuid.UUID
+	Limit  int64
+}
+
+func (q *Queries) GetSavedPostsForUser(ctx context.Context, arg GetSavedPostsForUserParams) ([]SavedPost, error) {
+	rows, err := q.db.QueryContext(ctx, getSavedPostsForUser, arg.UserID, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SavedPost
+	for rows.Next() {
+		var i SavedPost
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.PostID,
+			&i.Note,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedName,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
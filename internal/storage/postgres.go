@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"database/sql"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/akigithub888/aggreGATOR/sql/schema"
+	_ "github.com/lib/pq"
+	"github.com/pressly/goose/v3"
+)
+
+// openPostgres uses the sqlc queries in package database as they are.
+func openPostgres(dbURL string) (*DB, error) {
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{
+		Store:      database.New(db),
+		SQL:        db,
+		Dialect:    goose.DialectPostgres,
+		Migrations: schema.FS,
+		withTx: func(tx *sql.Tx) Store {
+			return database.New(tx)
+		},
+	}, nil
+}
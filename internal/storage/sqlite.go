@@ -0,0 +1,634 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/akigithub888/aggreGATOR/internal/sqlitedb"
+	sqliteschema "github.com/akigithub888/aggreGATOR/sql/sqlite/schema"
+	"github.com/google/uuid"
+	_ "github.com/ncruces/go-sqlite3/driver"
+	_ "github.com/ncruces/go-sqlite3/embed"
+	"github.com/pressly/goose/v3"
+)
+
+// SQLiteScheme starts db_url values that name a SQLite database file.
+const SQLiteScheme = "sqlite://"
+
+// openSQLite opens, and creates if needed, the database file at path. A
+// leading ~/ is the home directory. Times are written in SQLite's own
+// UTC format so that they compare correctly with datetime('now').
+func openSQLite(path string) (*DB, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, rest)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+
+	dsn := "file:" + path + "?_txlock=immediate&_timefmt=sqlite" +
+		"&_pragma=busy_timeout(10000)&_pragma=foreign_keys(1)&_pragma=journal_mode(wal)"
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{
+		Store:      &sqliteStore{db: db, q: sqlitedb.New(db)},
+		SQL:        db,
+		Dialect:    goose.DialectSQLite3,
+		Migrations: sqliteschema.FS,
+		withTx: func(tx *sql.Tx) Store {
+			return &sqliteStore{q: sqlitedb.New(tx)}
+		},
+	}, nil
+}
+
+// sqliteStore runs the SQLite queries in package sqlitedb and converts
+// their results to the PostgreSQL types. Where SQLite lacks a feature
+// the PostgreSQL query relies on, the difference is made up here.
+type sqliteStore struct {
+	// db is nil if the store already runs in a transaction.
+	db *sql.DB
+	q  *sqlitedb.Queries
+}
+
+// inTx runs fn in a transaction, or in the store's own one if it has one.
+func (s *sqliteStore) inTx(ctx context.Context, fn func(q *sqlitedb.Queries) error) error {
+	if s.db == nil {
+		return fn(s.q)
+	}
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(s.q.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := s.q.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	return database.User(user), err
+}
+
+func (s *sqliteStore) GetUserByName(ctx context.Context, name string) (database.GetUserByNameRow, error) {
+	user, err := s.q.GetUserByName(ctx, name)
+	return database.GetUserByNameRow(user), err
+}
+
+func (s *sqliteStore) GetUsers(ctx context.Context) ([]database.User, error) {
+	users, err := s.q.GetUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.User, 0, len(users))
+	for _, user := range users {
+		result = append(result, database.User(user))
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) DeleteAllUsers(ctx context.Context) error {
+	return s.q.DeleteAllUsers(ctx)
+}
+
+func (s *sqliteStore) CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error) {
+	feed, err := s.q.CreateFeed(ctx, sqlitedb.CreateFeedParams{
+		ID:                   arg.ID,
+		CreatedAt:            arg.CreatedAt,
+		UpdatedAt:            arg.UpdatedAt,
+		Name:                 arg.Name,
+		Url:                  arg.Url,
+		UserID:               arg.UserID,
+		FetchIntervalSeconds: nullInt64(arg.FetchIntervalSeconds),
+	})
+	if err != nil {
+		return database.Feed{}, err
+	}
+	return database.Feed{
+		ID:                    feed.ID,
+		CreatedAt:             feed.CreatedAt,
+		UpdatedAt:             feed.UpdatedAt,
+		Name:                  feed.Name,
+		Url:                   feed.Url,
+		UserID:                feed.UserID,
+		LastFetchedAt:         feed.LastFetchedAt,
+		Etag:                  feed.Etag,
+		LastModified:          feed.LastModified,
+		ClaimedUntil:          feed.ClaimedUntil,
+		LastError:             feed.LastError,
+		LastErrorAt:           feed.LastErrorAt,
+		ConsecutiveFailures:   int32(feed.ConsecutiveFailures),
+		DisabledAt:            feed.DisabledAt,
+		NextFetchAt:           feed.NextFetchAt,
+		FetchIntervalSeconds:  nullInt32(feed.FetchIntervalSeconds),
+		HintedIntervalSeconds: nullInt32(feed.HintedIntervalSeconds),
+		DisabledReason:        feed.DisabledReason,
+	}, nil
+}
+
+func (s *sqliteStore) GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error) {
+	feeds, err := s.q.GetFeeds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.GetFeedsRow, 0, len(feeds))
+	for _, feed := range feeds {
+		result = append(result, database.GetFeedsRow(feed))
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) GetFeedByURL(ctx context.Context, url string) (database.GetFeedByURLRow, error) {
+	feed, err := s.q.GetFeedByURL(ctx, url)
+	return database.GetFeedByURLRow(feed), err
+}
+
+func (s *sqliteStore) GetFeedsWithErrors(ctx context.Context) ([]database.GetFeedsWithErrorsRow, error) {
+	feeds, err := s.q.GetFeedsWithErrors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.GetFeedsWithErrorsRow, 0, len(feeds))
+	for _, feed := range feeds {
+		result = append(result, database.GetFeedsWithErrorsRow{
+			FeedID:              feed.FeedID,
+			FeedName:            feed.FeedName,
+			FeedUrl:             feed.FeedUrl,
+			UserName:            feed.UserName,
+			LastError:           feed.LastError,
+			LastErrorAt:         feed.LastErrorAt,
+			ConsecutiveFailures: int32(feed.ConsecutiveFailures),
+			DisabledAt:          feed.DisabledAt,
+			DisabledReason:      feed.DisabledReason,
+		})
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) CountFeedDependents(ctx context.Context, feedID uuid.UUID) (database.CountFeedDependentsRow, error) {
+	counts, err := s.q.CountFeedDependents(ctx, feedID)
+	return database.CountFeedDependentsRow(counts), err
+}
+
+func (s *sqliteStore) DeleteFeed(ctx context.Context, arg database.DeleteFeedParams) (int64, error) {
+	return s.q.DeleteFeed(ctx, sqlitedb.DeleteFeedParams(arg))
+}
+
+func (s *sqliteStore) RenameFeed(ctx context.Context, arg database.RenameFeedParams) error {
+	return s.q.RenameFeed(ctx, sqlitedb.RenameFeedParams{Name: arg.Name, ID: arg.ID})
+}
+
+func (s *sqliteStore) SetFeedURL(ctx context.Context, arg database.SetFeedURLParams) error {
+	return s.q.SetFeedURL(ctx, sqlitedb.SetFeedURLParams{Url: arg.Url, ID: arg.ID})
+}
+
+// MoveFeedURL updates the feed and its aliases in one transaction, which
+// the PostgreSQL query does in a single statement.
+func (s *sqliteStore) MoveFeedURL(ctx context.Context, arg database.MoveFeedURLParams) (int64, error) {
+	var moved int64
+	err := s.inTx(ctx, func(q *sqlitedb.Queries) error {
+		var err error
+		moved, err = q.MoveFeedURL(ctx, sqlitedb.MoveFeedURLParams{
+			NewUrl: arg.NewUrl,
+			ID:     arg.ID,
+			OldUrl: arg.OldUrl,
+		})
+		if err != nil || moved == 0 {
+			return err
+		}
+		err = q.DeleteFeedAlias(ctx, sqlitedb.DeleteFeedAliasParams{Url: arg.NewUrl, FeedID: arg.ID})
+		if err != nil {
+			return err
+		}
+		return q.AddFeedAlias(ctx, sqlitedb.AddFeedAliasParams{Url: arg.OldUrl, FeedID: arg.ID})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+func (s *sqliteStore) SetFeedInterval(ctx context.Context, arg database.SetFeedIntervalParams) error {
+	return s.q.SetFeedInterval(ctx, sqlitedb.SetFeedIntervalParams{
+		FetchIntervalSeconds: nullInt64(arg.FetchIntervalSeconds),
+		ID:                   arg.ID,
+	})
+}
+
+func (s *sqliteStore) EnableFeed(ctx context.Context, id uuid.UUID) error {
+	return s.q.EnableFeed(ctx, id)
+}
+
+// CreateFeedFollow looks up the names separately, since SQLite can't
+// insert within a WITH clause.
+func (s *sqliteStore) CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error) {
+	follow, err := s.q.CreateFeedFollow(ctx, sqlitedb.CreateFeedFollowParams(arg))
+	if err != nil {
+		return database.CreateFeedFollowRow{}, err
+	}
+	row, err := s.q.GetFeedFollow(ctx, follow.ID)
+	return database.CreateFeedFollowRow(row), err
+}
+
+func (s *sqliteStore) GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error) {
+	follows, err := s.q.GetFeedFollowsForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.GetFeedFollowsForUserRow, 0, len(follows))
+	for _, follow := range follows {
+		result = append(result, database.GetFeedFollowsForUserRow(follow))
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error {
+	return s.q.DeleteFeedFollow(ctx, sqlitedb.DeleteFeedFollowParams(arg))
+}
+
+func (s *sqliteStore) ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.ClaimFeedsToFetchRow, error) {
+	feeds, err := s.q.ClaimFeedsToFetch(ctx, sqlitedb.ClaimFeedsToFetchParams{
+		LeaseSeconds: int64(arg.LeaseSeconds),
+		BatchSize:    int64(arg.BatchSize),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.ClaimFeedsToFetchRow, 0, len(feeds))
+	for _, feed := range feeds {
+		result = append(result, database.ClaimFeedsToFetchRow{
+			ID:                    feed.ID,
+			Name:                  feed.Name,
+			Url:                   feed.Url,
+			UserID:                feed.UserID,
+			LastFetchedAt:         feed.LastFetchedAt,
+			CreatedAt:             feed.CreatedAt,
+			UpdatedAt:             feed.UpdatedAt,
+			Etag:                  feed.Etag,
+			LastModified:          feed.LastModified,
+			ConsecutiveFailures:   int32(feed.ConsecutiveFailures),
+			FetchIntervalSeconds:  nullInt32(feed.FetchIntervalSeconds),
+			HintedIntervalSeconds: nullInt32(feed.HintedIntervalSeconds),
+		})
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) ClaimFeed(ctx context.Context, arg database.ClaimFeedParams) (database.ClaimFeedRow, error) {
+	feed, err := s.q.ClaimFeed(ctx, sqlitedb.ClaimFeedParams{
+		LeaseSeconds: int64(arg.LeaseSeconds),
+		ID:           arg.ID,
+	})
+	if err != nil {
+		return database.ClaimFeedRow{}, err
+	}
+	return database.ClaimFeedRow{
+		ID:                    feed.ID,
+		Name:                  feed.Name,
+		Url:                   feed.Url,
+		UserID:                feed.UserID,
+		LastFetchedAt:         feed.LastFetchedAt,
+		CreatedAt:             feed.CreatedAt,
+		UpdatedAt:             feed.UpdatedAt,
+		Etag:                  feed.Etag,
+		LastModified:          feed.LastModified,
+		ConsecutiveFailures:   int32(feed.ConsecutiveFailures),
+		FetchIntervalSeconds:  nullInt32(feed.FetchIntervalSeconds),
+		HintedIntervalSeconds: nullInt32(feed.HintedIntervalSeconds),
+	}, nil
+}
+
+func (s *sqliteStore) ReleaseFeedClaim(ctx context.Context, id uuid.UUID) error {
+	return s.q.ReleaseFeedClaim(ctx, id)
+}
+
+func (s *sqliteStore) RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error {
+	return s.q.RecordFeedSuccess(ctx, sqlitedb.RecordFeedSuccessParams{
+		NextFetchInSeconds:    int64(arg.NextFetchInSeconds),
+		Etag:                  arg.Etag,
+		LastModified:          arg.LastModified,
+		HintedIntervalSeconds: nullInt64(arg.HintedIntervalSeconds),
+		ID:                    arg.ID,
+	})
+}
+
+func (s *sqliteStore) RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.RecordFeedFailureRow, error) {
+	row, err := s.q.RecordFeedFailure(ctx, sqlitedb.RecordFeedFailureParams{
+		LastError:          arg.LastError,
+		NextFetchInSeconds: int64(arg.NextFetchInSeconds),
+		MaxFailures:        int64(arg.MaxFailures),
+		DisabledReason:     arg.DisabledReason,
+		ID:                 arg.ID,
+	})
+	if err != nil {
+		return database.RecordFeedFailureRow{}, err
+	}
+	return database.RecordFeedFailureRow{
+		ConsecutiveFailures: int32(row.ConsecutiveFailures),
+		DisabledAt:          row.DisabledAt,
+	}, nil
+}
+
+func (s *sqliteStore) CreateFeedFetch(ctx context.Context, arg database.CreateFeedFetchParams) error {
+	return s.q.CreateFeedFetch(ctx, sqlitedb.CreateFeedFetchParams{
+		ID:         arg.ID,
+		FeedID:     arg.FeedID,
+		StartedAt:  arg.StartedAt,
+		FinishedAt: arg.FinishedAt,
+		Status:     arg.Status,
+		Items:      int64(arg.Items),
+		Inserted:   int64(arg.Inserted),
+		Updated:    int64(arg.Updated),
+		Duplicates: int64(arg.Duplicates),
+		Failed:     int64(arg.Failed),
+		Error:      arg.Error,
+	})
+}
+
+func (s *sqliteStore) GetFeedPostingStats(ctx context.Context, feedID uuid.UUID) (int32, error) {
+	gap, err := s.q.GetFeedPostingStats(ctx, feedID)
+	return int32(gap), err
+}
+
+// UpsertPost counts what happened from the id the SQLite query returns,
+// if any: a new post keeps the id it was given.
+func (s *sqliteStore) UpsertPost(ctx context.Context, arg database.UpsertPostParams) (database.UpsertPostRow, error) {
+	id, err := s.q.UpsertPost(ctx, sqlitedb.UpsertPostParams(arg))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return database.UpsertPostRow{}, nil
+	case err != nil:
+		return database.UpsertPostRow{}, err
+	case id == arg.ID:
+		return database.UpsertPostRow{Inserted: 1}, nil
+	default:
+		return database.UpsertPostRow{Updated: 1}, nil
+	}
+}
+
+func (s *sqliteStore) CreatePostRevision(ctx context.Context, arg database.CreatePostRevisionParams) error {
+	return s.q.CreatePostRevision(ctx, sqlitedb.CreatePostRevisionParams(arg))
+}
+
+func (s *sqliteStore) AdoptLegacyPost(ctx context.Context, arg database.AdoptLegacyPostParams) error {
+	return s.q.AdoptLegacyPost(ctx, sqlitedb.AdoptLegacyPostParams(arg))
+}
+
+func (s *sqliteStore) GetPost(ctx context.Context, id uuid.UUID) (database.Post, error) {
+	post, err := s.q.GetPost(ctx, id)
+	if err != nil {
+		return database.Post{}, err
+	}
+	return database.Post{
+		ID:          post.ID,
+		CreatedAt:   post.CreatedAt,
+		UpdatedAt:   post.UpdatedAt,
+		Title:       post.Title,
+		Url:         post.Url,
+		Description: post.Description,
+		PublishedAt: post.PublishedAt,
+		FeedID:      post.FeedID,
+		Guid:        post.Guid,
+		ContentHash: post.ContentHash,
+		Authors:     post.Authors,
+	}, nil
+}
+
+func (s *sqliteStore) GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]database.PostRevision, error) {
+	revisions, err := s.q.GetPostRevisions(ctx, postID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.PostRevision, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, database.PostRevision(revision))
+	}
+	return result, nil
+}
+
+// GetPostsForUser passes the feeds filter as a JSON array, since SQLite
+// has no arrays, and works out each row's sort time itself.
+func (s *sqliteStore) GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error) {
+	feeds := arg.Feeds
+	if feeds == nil {
+		feeds = []string{}
+	}
+	feedsJSON, err := json.Marshal(feeds)
+	if err != nil {
+		return nil, err
+	}
+	posts, err := s.q.GetPostsForUser(ctx, sqlitedb.GetPostsForUserParams{
+		UserID:     arg.UserID,
+		UnreadOnly: arg.UnreadOnly,
+		Feeds:      string(feedsJSON),
+		Since:      arg.Since,
+		Until:      arg.Until,
+		AfterID:    arg.AfterID,
+		Sort:       arg.Sort,
+		AfterFeed:  arg.AfterFeed,
+		AfterTime:  arg.AfterTime,
+		Offset:     int64(arg.Offset),
+		Limit:      int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.GetPostsForUserRow, 0, len(posts))
+	for _, post := range posts {
+		sortTime := post.CreatedAt
+		if arg.Sort != "fetched" && post.PublishedAt.Valid {
+			sortTime = post.PublishedAt.Time
+		}
+		result = append(result, database.GetPostsForUserRow{
+			ID:          post.ID,
+			CreatedAt:   post.CreatedAt,
+			UpdatedAt:   post.UpdatedAt,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Authors:     post.Authors,
+			FeedName:    post.FeedName,
+			ReadAt:      post.ReadAt,
+			SortTime:    sortTime,
+		})
+	}
+	return result, nil
+}
+
+var (
+	htmlTag         = regexp.MustCompile(`<[^>]*>`)
+	trailingHTMLTag = regexp.MustCompile(`<[^>]*$`)
+)
+
+// SearchPostsForUser translates the web search syntax PostgreSQL takes
+// into an FTS5 query. FTS5 snippets are cut from the raw description, so
+// HTML tags are stripped from them afterwards.
+//
+// Unlike on PostgreSQL, a query that only excludes terms, such as -foo,
+// finds nothing: FTS5 has no way to match every row but some.
+func (s *sqliteStore) SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error) {
+	query := ftsQuery(arg.Query)
+	if query == "" {
+		return nil, nil
+	}
+	posts, err := s.q.SearchPostsForUser(ctx, sqlitedb.SearchPostsForUserParams{
+		Query:  query,
+		UserID: arg.UserID,
+		Feed:   arg.Feed,
+		Since:  arg.Since,
+		Until:  arg.Until,
+		Limit:  int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.SearchPostsForUserRow, 0, len(posts))
+	for _, post := range posts {
+		snippet := htmlTag.ReplaceAllString(post.Snippet, " ")
+		snippet = trailingHTMLTag.ReplaceAllString(snippet, "")
+		result = append(result, database.SearchPostsForUserRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			PublishedAt: post.PublishedAt,
+			CreatedAt:   post.CreatedAt,
+			FeedName:    post.FeedName,
+			Rank:        float32(post.Rank),
+			Snippet:     strings.Join(strings.Fields(snippet), " "),
+		})
+	}
+	return result, nil
+}
+
+// ftsQuery turns a query in the syntax of PostgreSQL's
+// websearch_to_tsquery into FTS5 syntax: all words must match, "quoted
+// text" is a phrase, "or" between terms matches either and a leading -
+// excludes a term. Every term is quoted, so punctuation can't be taken
+// for FTS5 operators. Returns "" if there is no term to match, including
+// when all terms are excluded.
+func ftsQuery(query string) string {
+	var groups [][]string
+	var excluded []string
+	or := false
+	for {
+		query = strings.TrimLeftFunc(query, unicode.IsSpace)
+		if query == "" {
+			break
+		}
+		negate := query[0] == '-'
+		if negate {
+			query = query[1:]
+		}
+
+		var term string
+		if rest, ok := strings.CutPrefix(query, `"`); ok {
+			term, query, _ = strings.Cut(rest, `"`)
+		} else {
+			end := strings.IndexFunc(query, unicode.IsSpace)
+			if end < 0 {
+				end = len(query)
+			}
+			term, query = query[:end], query[end:]
+			if !negate && strings.EqualFold(term, "or") {
+				or = len(groups) > 0
+				continue
+			}
+		}
+		if strings.TrimSpace(term) == "" {
+			continue
+		}
+		term = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+
+		switch {
+		case negate:
+			excluded = append(excluded, term)
+		case or:
+			groups[len(groups)-1] = append(groups[len(groups)-1], term)
+		default:
+			groups = append(groups, []string{term})
+		}
+		or = false
+	}
+	if len(groups) == 0 {
+		return ""
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if len(group) == 1 {
+			parts = append(parts, group[0])
+		} else {
+			parts = append(parts, "("+strings.Join(group, " OR ")+")")
+		}
+	}
+	fts := strings.Join(parts, " AND ")
+	for _, term := range excluded {
+		fts += " NOT " + term
+	}
+	return fts
+}
+
+func (s *sqliteStore) MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error {
+	return s.q.MarkPostRead(ctx, sqlitedb.MarkPostReadParams(arg))
+}
+
+func (s *sqliteStore) MarkAllPostsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.q.MarkAllPostsRead(ctx, userID)
+}
+
+func (s *sqliteStore) MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) (int64, error) {
+	return s.q.MarkPostUnread(ctx, sqlitedb.MarkPostUnreadParams(arg))
+}
+
+func (s *sqliteStore) CreateSavedPost(ctx context.Context, arg database.CreateSavedPostParams) (database.SavedPost, error) {
+	saved, err := s.q.CreateSavedPost(ctx, sqlitedb.CreateSavedPostParams(arg))
+	return database.SavedPost(saved), err
+}
+
+func (s *sqliteStore) DeleteSavedPost(ctx context.Context, arg database.DeleteSavedPostParams) (int64, error) {
+	return s.q.DeleteSavedPost(ctx, sqlitedb.DeleteSavedPostParams{
+		UserID: arg.UserID,
+		ID:     uuid.NullUUID{UUID: arg.ID, Valid: true},
+	})
+}
+
+func (s *sqliteStore) GetSavedPostsForUser(ctx context.Context, arg database.GetSavedPostsForUserParams) ([]database.SavedPost, error) {
+	saved, err := s.q.GetSavedPostsForUser(ctx, sqlitedb.GetSavedPostsForUserParams{
+		UserID: arg.UserID,
+		Limit:  int64(arg.Limit),
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]database.SavedPost, 0, len(saved))
+	for _, post := range saved {
+		result = append(result, database.SavedPost(post))
+	}
+	return result, nil
+}
+
+func nullInt32(n sql.NullInt64) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(n.Int64), Valid: n.Valid}
+}
+
+func nullInt64(n sql.NullInt32) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n.Int32), Valid: n.Valid}
+}
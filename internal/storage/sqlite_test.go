@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+)
+
+func TestFTSQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{name: "words", query: "pgvector index", want: `"pgvector" AND "index"`},
+		{name: "extra spaces", query: "  pgvector \t index\n", want: `"pgvector" AND "index"`},
+		{name: "or", query: "a or b", want: `("a" OR "b")`},
+		{name: "or is case-insensitive", query: "a OR b", want: `("a" OR "b")`},
+		{name: "or chain", query: "a or b or c d", want: `("a" OR "b" OR "c") AND "d"`},
+		{name: "phrase and exclusion", query: `"x y" -z`, want: `"x y" NOT "z"`},
+		{name: "excluded phrase", query: `a -"x y"`, want: `"a" NOT "x y"`},
+		{name: "leading or", query: "or a", want: `"a"`},
+		{name: "trailing or", query: "a or", want: `"a"`},
+		{name: "excluded or is a word", query: "a -or", want: `"a" NOT "or"`},
+		{name: "quoted or is a word", query: `"or"`, want: `"or"`},
+		{name: "lone dash", query: "a - b", want: `"a" AND "b"`},
+		{name: "only a dash", query: "-", want: ""},
+		{name: "unclosed quote", query: `a "x y`, want: `"a" AND "x y"`},
+		{name: "empty quotes", query: `a ""`, want: `"a"`},
+		{name: "embedded quote", query: `say"what`, want: `"say""what"`},
+		{name: "fts5 syntax is quoted", query: "title:foo* NEAR(a)", want: `"title:foo*" AND "NEAR(a)"`},
+		{name: "hyphenated word", query: "pg-vector", want: `"pg-vector"`},
+		{name: "empty", query: "", want: ""},
+		{name: "exclusion only", query: "-foo", want: ""},
+		{name: "exclusions only", query: `-foo -"bar baz"`, want: ""},
+	}
+
+	// Every query must also be one FTS5 accepts.
+	db, err := sql.Open("sqlite3", "file::memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("CREATE VIRTUAL TABLE docs USING fts5(title, tokenize='porter unicode61')"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ftsQuery(tt.query)
+			if got != tt.want {
+				t.Errorf("ftsQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			if _, err := db.Exec("SELECT count(*) FROM docs(?)", got); err != nil {
+				t.Errorf("FTS5 rejects %q: %v", got, err)
+			}
+		})
+	}
+}
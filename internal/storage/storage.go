@@ -0,0 +1,104 @@
+// Package storage lets gator run on either PostgreSQL or SQLite. Both
+// backends implement Store with the types of the PostgreSQL queries in
+// package database, so the rest of gator doesn't care which one it has.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"strings"
+
+	"github.com/akigithub888/aggreGATOR/internal/database"
+	"github.com/google/uuid"
+	"github.com/pressly/goose/v3"
+)
+
+// Store is every query gator runs.
+type Store interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error)
+	GetUserByName(ctx context.Context, name string) (database.GetUserByNameRow, error)
+	GetUsers(ctx context.Context) ([]database.User, error)
+	DeleteAllUsers(ctx context.Context) error
+
+	CreateFeed(ctx context.Context, arg database.CreateFeedParams) (database.Feed, error)
+	GetFeeds(ctx context.Context) ([]database.GetFeedsRow, error)
+	GetFeedByURL(ctx context.Context, url string) (database.GetFeedByURLRow, error)
+	GetFeedsWithErrors(ctx context.Context) ([]database.GetFeedsWithErrorsRow, error)
+	CountFeedDependents(ctx context.Context, feedID uuid.UUID) (database.CountFeedDependentsRow, error)
+	DeleteFeed(ctx context.Context, arg database.DeleteFeedParams) (int64, error)
+	RenameFeed(ctx context.Context, arg database.RenameFeedParams) error
+	SetFeedURL(ctx context.Context, arg database.SetFeedURLParams) error
+	MoveFeedURL(ctx context.Context, arg database.MoveFeedURLParams) (int64, error)
+	SetFeedInterval(ctx context.Context, arg database.SetFeedIntervalParams) error
+	EnableFeed(ctx context.Context, id uuid.UUID) error
+
+	CreateFeedFollow(ctx context.Context, arg database.CreateFeedFollowParams) (database.CreateFeedFollowRow, error)
+	GetFeedFollowsForUser(ctx context.Context, userID uuid.UUID) ([]database.GetFeedFollowsForUserRow, error)
+	DeleteFeedFollow(ctx context.Context, arg database.DeleteFeedFollowParams) error
+
+	ClaimFeedsToFetch(ctx context.Context, arg database.ClaimFeedsToFetchParams) ([]database.ClaimFeedsToFetchRow, error)
+	ClaimFeed(ctx context.Context, arg database.ClaimFeedParams) (database.ClaimFeedRow, error)
+	ReleaseFeedClaim(ctx context.Context, id uuid.UUID) error
+	RecordFeedSuccess(ctx context.Context, arg database.RecordFeedSuccessParams) error
+	RecordFeedFailure(ctx context.Context, arg database.RecordFeedFailureParams) (database.RecordFeedFailureRow, error)
+	CreateFeedFetch(ctx context.Context, arg database.CreateFeedFetchParams) error
+	GetFeedPostingStats(ctx context.Context, feedID uuid.UUID) (int32, error)
+
+	UpsertPost(ctx context.Context, arg database.UpsertPostParams) (database.UpsertPostRow, error)
+	CreatePostRevision(ctx context.Context, arg database.CreatePostRevisionParams) error
+	AdoptLegacyPost(ctx context.Context, arg database.AdoptLegacyPostParams) error
+	GetPost(ctx context.Context, id uuid.UUID) (database.Post, error)
+	GetPostRevisions(ctx context.Context, postID uuid.UUID) ([]database.PostRevision, error)
+	GetPostsForUser(ctx context.Context, arg database.GetPostsForUserParams) ([]database.GetPostsForUserRow, error)
+	SearchPostsForUser(ctx context.Context, arg database.SearchPostsForUserParams) ([]database.SearchPostsForUserRow, error)
+	MarkPostRead(ctx context.Context, arg database.MarkPostReadParams) error
+	MarkAllPostsRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkPostUnread(ctx context.Context, arg database.MarkPostUnreadParams) (int64, error)
+
+	CreateSavedPost(ctx context.Context, arg database.CreateSavedPostParams) (database.SavedPost, error)
+	DeleteSavedPost(ctx context.Context, arg database.DeleteSavedPostParams) (int64, error)
+	GetSavedPostsForUser(ctx context.Context, arg database.GetSavedPostsForUserParams) ([]database.SavedPost, error)
+}
+
+var _ Store = (*database.Queries)(nil)
+
+// DB is an open database: its queries, plus what goose needs to manage
+// its schema.
+type DB struct {
+	Store
+	SQL        *sql.DB
+	Dialect    goose.Dialect
+	Migrations fs.FS
+
+	// withTx returns a Store whose queries run in tx.
+	withTx func(tx *sql.Tx) Store
+}
+
+// Open connects to the database dbURL names: a SQLite file for
+// sqlite:// URLs, PostgreSQL for anything else.
+func Open(dbURL string) (*DB, error) {
+	if path, ok := strings.CutPrefix(dbURL, SQLiteScheme); ok {
+		return openSQLite(path)
+	}
+	return openPostgres(dbURL)
+}
+
+// InTx runs fn with a Store whose queries all run in one transaction. The
+// transaction is committed if fn returns nil and rolled back otherwise.
+func (db *DB) InTx(ctx context.Context, fn func(Store) error) error {
+	tx, err := db.SQL.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := fn(db.withTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Close closes the underlying connection pool.
+func (db *DB) Close() error {
+	return db.SQL.Close()
+}
@@ -7,20 +7,19 @@ import (
 	"github.com/akigithub888/aggreGATOR/internal/database"
 )
 
-func middlewareLoggedIn(handler func(s *state, cmd command, user database.GetUserByNameRow) error) func(*state, command) error {
-	return func(s *state, cmd command) error {
-
-		ctx := context.Background()
-
+func middlewareLoggedIn(handler func(ctx context.Context, s *state, cmd command, user database.GetUserByNameRow) error) func(context.Context, *state, command) error {
+	return func(ctx context.Context, s *state, cmd command) error {
+		// The current user belongs to the active profile, since each
+		// profile may point at a different database.
 		if s.cfg.CurrentUserName == "" {
-			return fmt.Errorf("you must be logged in to run this command")
+			return fmt.Errorf("you must be logged in to run this command (profile %s)", s.cfg.Profile())
 		}
 
 		user, err := s.db.GetUserByName(ctx, s.cfg.CurrentUserName)
 		if err != nil {
-			return fmt.Errorf("current user not found")
+			return fmt.Errorf("current user %s not found (profile %s)", s.cfg.CurrentUserName, s.cfg.Profile())
 		}
 
-		return handler(s, cmd, user)
+		return handler(ctx, s, cmd, user)
 	}
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/akigithub888/aggreGATOR/internal/storage"
+	"github.com/pressly/goose/v3"
+)
+
+// newMigrationProvider runs the migrations embedded in the binary for the
+// database's backend. It keeps track of them in goose's usual
+// goose_db_version table, so databases migrated with the goose CLI carry
+// on where they left off.
+func newMigrationProvider(db *storage.DB) (*goose.Provider, error) {
+	provider, err := goose.NewProvider(db.Dialect, db.SQL, db.Migrations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load migrations: %w", err)
+	}
+	return provider, nil
+}
+
+// checkSchema fails unless the database has every migration this binary
+// knows about, so that commands don't die later with obscure SQL errors.
+func checkSchema(ctx context.Context, db *storage.DB) error {
+	provider, err := newMigrationProvider(db)
+	if err != nil {
+		return err
+	}
+	current, target, err := provider.GetVersions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check database schema: %w", err)
+	}
+	if current < target {
+		return fmt.Errorf("database schema is at version %d but gator needs version %d; run: gator db migrate up", current, target)
+	}
+	if current > target {
+		fmt.Fprintf(os.Stderr, "Database schema version %d is newer than this gator (%d); consider upgrading gator\n", current, target)
+	}
+	return nil
+}
+
+func handlerDB(ctx context.Context, s *state, cmd command) error {
+	if len(cmd.args) < 1 {
+		return fmt.Errorf("usage: db <migrate> ...")
+	}
+	sub := command{
+		name: cmd.name + " " + cmd.args[0],
+		args: cmd.args[1:],
+	}
+	switch cmd.args[0] {
+	case "migrate":
+		return handlerDBMigrate(ctx, s, sub)
+	default:
+		return fmt.Errorf("unknown db command: %s", cmd.args[0])
+	}
+}
+
+func handlerDBMigrate(ctx context.Context, s *state, cmd command) error {
+	provider, err := newMigrationProvider(s.conn)
+	if err != nil {
+		return err
+	}
+
+	action := "up"
+	if len(cmd.args) > 0 {
+		action = cmd.args[0]
+	}
+	switch {
+	case action == "up" && len(cmd.args) <= 1:
+		results, err := provider.Up(ctx)
+		printMigrationResults(results)
+		if err != nil {
+			return migrationError(err)
+		}
+		if len(results) == 0 {
+			fmt.Println("Database schema is up to date.")
+		}
+	case action == "down" && len(cmd.args) == 1:
+		result, err := provider.Down(ctx)
+		if result != nil {
+			printMigrationResults([]*goose.MigrationResult{result})
+		}
+		if err != nil {
+			if errors.Is(err, goose.ErrNoNextVersion) {
+				fmt.Println("No migrations to roll back.")
+				return nil
+			}
+			return migrationError(err)
+		}
+	case action == "to" && len(cmd.args) == 2:
+		version, err := strconv.ParseInt(cmd.args[1], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version: %s", cmd.args[1])
+		}
+		current, target, err := provider.GetVersions(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get schema version: %w", err)
+		}
+		if version > target {
+			return fmt.Errorf("there is no migration %d; the latest is %d", version, target)
+		}
+		var results []*goose.MigrationResult
+		if version >= current {
+			results, err = provider.UpTo(ctx, version)
+		} else {
+			results, err = provider.DownTo(ctx, version)
+		}
+		printMigrationResults(results)
+		if err != nil {
+			return migrationError(err)
+		}
+		fmt.Println("Database schema is at version", version)
+	case action == "status" && len(cmd.args) == 1:
+		statuses, err := provider.Status(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get migration status: %w", err)
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.State == goose.StateApplied {
+				applied = "applied " + status.AppliedAt.Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%3d %-45s %s\n", status.Source.Version, path.Base(status.Source.Path), applied)
+		}
+	default:
+		return fmt.Errorf("usage: db migrate [up|down|status|to N]")
+	}
+	return nil
+}
+
+func printMigrationResults(results []*goose.MigrationResult) {
+	for _, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		fmt.Printf("Migrated %s %s (%s)\n",
+			result.Direction, path.Base(result.Source.Path), result.Duration.Round(1e6))
+	}
+}
+
+// migrationError unwraps goose's partial error, which otherwise repeats
+// the migrations that did succeed.
+func migrationError(err error) error {
+	var partial *goose.PartialError
+	if errors.As(err, &partial) && partial.Failed != nil {
+		return fmt.Errorf("migration %s failed: %w", path.Base(partial.Failed.Source.Path), partial.Err)
+	}
+	return fmt.Errorf("migration failed: %w", err)
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// outputFormat selects how listing commands print their results.
+type outputFormat string
+
+const (
+	outputText  outputFormat = "text"
+	outputJSON  outputFormat = "json"
+	outputJSONL outputFormat = "jsonl"
+	outputCSV   outputFormat = "csv"
+	outputTable outputFormat = "table"
+)
+
+// machineReadable reports whether the format is meant for scripts, in
+// which case errors are reported as JSON too.
+func (f outputFormat) machineReadable() bool {
+	return f == outputJSON || f == outputJSONL || f == outputCSV
+}
+
+// writeRecords prints records, a slice of structs, in a structured
+// format. Field names come from the json tags so every format uses the
+// same names.
+func writeRecords(w io.Writer, format outputFormat, records any) error {
+	v := reflect.ValueOf(records)
+	if v.Kind() != reflect.Slice {
+		return fmt.Errorf("records must be a slice, got %T", records)
+	}
+
+	switch format {
+	case outputJSON:
+		if v.IsNil() {
+			records = []struct{}{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	case outputJSONL:
+		enc := json.NewEncoder(w)
+		for i := 0; i < v.Len(); i++ {
+			if err := enc.Encode(v.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputCSV:
+		cw := csv.NewWriter(w)
+		cw.Write(recordHeader(v.Type().Elem()))
+		for i := 0; i < v.Len(); i++ {
+			cw.Write(recordRow(v.Index(i)))
+		}
+		cw.Flush()
+		return cw.Error()
+	case outputTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		header := recordHeader(v.Type().Elem())
+		for i, name := range header {
+			header[i] = strings.ToUpper(name)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+		for i := 0; i < v.Len(); i++ {
+			fmt.Fprintln(tw, strings.Join(recordRow(v.Index(i)), "\t"))
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+// writeError reports err as a JSON object, for formats read by scripts.
+func writeError(w io.Writer, err error) {
+	json.NewEncoder(w).Encode(struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func recordHeader(t reflect.Type) []string {
+	header := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		header = append(header, name)
+	}
+	return header
+}
+
+func recordRow(v reflect.Value) []string {
+	row := make([]string, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		row = append(row, formatField(v.Field(i)))
+	}
+	return row
+}
+
+// formatField renders a single field for csv and table output. Nil
+// pointers are empty and times use RFC 3339, as in JSON.
+func formatField(v reflect.Value) string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case fmt.Stringer:
+		return val.String()
+	case float32:
+		return fmt.Sprintf("%.3f", val)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// timePtr turns a nullable timestamp into one that encodes as null.
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+type postRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	FeedName    string     `json:"feed_name"`
+	Authors     string     `json:"authors"`
+	PublishedAt *time.Time `json:"published_at"`
+	FetchedAt   time.Time  `json:"fetched_at"`
+	Read        bool       `json:"read"`
+	Cursor      string     `json:"cursor"`
+}
+
+type savedPostRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	FeedName    string     `json:"feed_name"`
+	PublishedAt *time.Time `json:"published_at"`
+	SavedAt     time.Time  `json:"saved_at"`
+	Note        string     `json:"note"`
+}
+
+type searchResultRecord struct {
+	ID          uuid.UUID  `json:"id"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	FeedName    string     `json:"feed_name"`
+	PublishedAt *time.Time `json:"published_at"`
+	Rank        float32    `json:"rank"`
+	Snippet     string     `json:"snippet"`
+}
+
+type feedRecord struct {
+	ID            uuid.UUID  `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	CreatedBy     string     `json:"created_by"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	NextFetchAt   *time.Time `json:"next_fetch_at"`
+	DisabledAt    *time.Time `json:"disabled_at"`
+}
+
+type feedErrorRecord struct {
+	ID                  uuid.UUID  `json:"id"`
+	Name                string     `json:"name"`
+	URL                 string     `json:"url"`
+	CreatedBy           string     `json:"created_by"`
+	ConsecutiveFailures int32      `json:"consecutive_failures"`
+	LastError           string     `json:"last_error"`
+	LastErrorAt         *time.Time `json:"last_error_at"`
+	DisabledAt          *time.Time `json:"disabled_at"`
+	DisabledReason      string     `json:"disabled_reason"`
+}
+
+type followRecord struct {
+	ID          uuid.UUID `json:"id"`
+	FeedID      uuid.UUID `json:"feed_id"`
+	FeedName    string    `json:"feed_name"`
+	FeedURL     string    `json:"feed_url"`
+	FollowedAt  time.Time `json:"followed_at"`
+	UnreadCount int64     `json:"unread_count"`
+}
+
+type settingRecord struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type profileRecord struct {
+	Name            string `json:"name"`
+	DBURL           string `json:"db_url"`
+	CurrentUserName string `json:"current_user_name"`
+	Active          bool   `json:"active"`
+}
+
+type userRecord struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Current   bool      `json:"current"`
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+)
+
+const rdfNamespace = "http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+
+// RDFFeed is an RSS 1.0 document. Unlike RSS 2.0, items are siblings of
+// the channel rather than children of it.
+type RDFFeed struct {
+	Channel struct {
+		Title           string `xml:"title"`
+		Link            string `xml:"link"`
+		Description     string `xml:"description"`
+		UpdatePeriod    string `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+	} `xml:"channel"`
+	Item []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	About       string   `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	Date        string   `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Creator     []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+}
+
+func parseRDF(body []byte) (*Feed, error) {
+	var rdf RDFFeed
+	if err := xml.Unmarshal(body, &rdf); err != nil {
+		return nil, fmt.Errorf("failed to parse RDF feed: %w", err)
+	}
+
+	feed := &Feed{
+		Title:          html.UnescapeString(rdf.Channel.Title),
+		Link:           rdf.Channel.Link,
+		Description:    rdf.Channel.Description,
+		UpdateInterval: syndicationInterval(rdf.Channel.UpdatePeriod, rdf.Channel.UpdateFrequency),
+		Items:          make([]FeedItem, 0, len(rdf.Item)),
+	}
+	for _, item := range rdf.Item {
+		link := strings.TrimSpace(item.Link)
+		if link == "" {
+			link = item.About
+		}
+
+		feed.Items = append(feed.Items, FeedItem{
+			ID:          strings.TrimSpace(item.About),
+			Title:       html.UnescapeString(item.Title),
+			Link:        link,
+			RawLink:     link,
+			Description: html.UnescapeString(item.Description),
+			PubDate:     strings.TrimSpace(item.Date),
+			Authors:     nonEmpty(item.Creator),
+		})
+	}
+
+	return feed, nil
+}
@@ -1,31 +1,102 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"html"
 	"io"
 	"net/http"
+	"strings"
+	"time"
 )
 
+// Feed is the format-independent view of a fetched feed. Every supported
+// format is decoded into its own structs and then mapped onto this shape,
+// which is what scrapeFeeds and savePost work with.
+type Feed struct {
+	Title       string
+	Link        string
+	Description string
+	// UpdateInterval is the publisher's hint for how often the feed is
+	// worth polling, or 0 if it gave none.
+	UpdateInterval time.Duration
+	Items          []FeedItem
+}
+
+// FeedItem is a single entry of a Feed.
+type FeedItem struct {
+	// ID is the identifier the feed gives the item (RSS <guid>, Atom
+	// <id>, JSON Feed id, RDF rdf:about), or empty if it has none.
+	ID    string
+	Title string
+	Link  string
+	// RawLink is the link that identifies an item without an ID, see
+	// fallbackGUID. For RSS it is the <link> exactly as written, which
+	// Link trims and fills in from a permalink guid; the other formats use
+	// Link as is.
+	RawLink     string
+	Description string
+	PubDate     string
+	// Authors are the names of the item's authors, if the feed gives any.
+	Authors []string
+}
+
 type RSSFeed struct {
 	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
+		Title           string    `xml:"title"`
+		Link            string    `xml:"link"`
+		Description     string    `xml:"description"`
+		TTL             string    `xml:"ttl"`
+		UpdatePeriod    string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+		UpdateFrequency string    `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+		Item            []RSSItem `xml:"item"`
 	} `xml:"channel"`
 }
 
 type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	GUID        RSSGUID  `xml:"guid"`
+	Author      string   `xml:"author"`
+	Creator     []string `xml:"http://purl.org/dc/elements/1.1/ creator"`
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+type RSSGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink string `xml:"isPermaLink,attr"`
+}
+
+// feedCache holds the validators from a previous response, used to make
+// conditional requests.
+type feedCache struct {
+	ETag         string
+	LastModified string
+}
+
+// fetchResult is the outcome of a successful fetch. When the server
+// answered 304 Not Modified, NotModified is set and Feed is nil.
+type fetchResult struct {
+	Feed        *Feed
+	NotModified bool
+	Cache       feedCache
+	// MovedTo is the feed's new URL if it was reached through permanent
+	// redirects only, or empty if it has not moved.
+	MovedTo string
+}
+
+// errFeedGone is returned for feeds whose server answers 410 Gone, which
+// means they will not come back.
+var errFeedGone = errors.New("feed is gone (410 Gone)")
+
+// maxRedirects matches the limit of http.DefaultClient.
+const maxRedirects = 10
+
+func fetchFeed(ctx context.Context, feedURL string, cache feedCache) (*fetchResult, error) {
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodGet,
@@ -36,12 +107,63 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "gator")
+	if cache.ETag != "" {
+		req.Header.Set("If-None-Match", cache.ETag)
+	}
+	if cache.LastModified != "" {
+		req.Header.Set("If-Modified-Since", cache.LastModified)
+	}
+
+	// Follow redirects as usual, but remember where the chain ends if
+	// every hop in it is permanent: that is the feed's new home. A
+	// temporary redirect anywhere in the chain means the feed has not
+	// really moved.
+	movedTo := ""
+	permanent := true
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			switch req.Response.StatusCode {
+			case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+				if permanent {
+					movedTo = req.URL.String()
+				}
+			default:
+				permanent = false
+				movedTo = ""
+			}
+			return nil
+		},
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch feed: %w", err)
 	}
 	defer resp.Body.Close()
+
+	// A 304 may omit validators that haven't changed, so keep the ones
+	// we sent unless the server provides new ones.
+	result := &fetchResult{Cache: cache}
+	if movedTo != feedURL {
+		result.MovedTo = movedTo
+	}
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		result.Cache.ETag = etag
+	}
+	if lastModified := resp.Header.Get("Last-Modified"); lastModified != "" {
+		result.Cache.LastModified = lastModified
+	}
+
+	if resp.StatusCode == http.StatusNotModified {
+		result.NotModified = true
+		return result, nil
+	}
+	if resp.StatusCode == http.StatusGone {
+		return nil, errFeedGone
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
 	}
@@ -49,16 +171,118 @@ func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
 	}
-	var feed RSSFeed
-	if err := xml.Unmarshal(body, &feed); err != nil {
+	result.Feed, err = parseFeed(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// parseFeed detects the format of a feed document, JSON Feed from the
+// content type or body and XML formats from the root element, and decodes
+// it with the matching parser.
+func parseFeed(contentType string, body []byte) (*Feed, error) {
+	if isJSONFeed(contentType, body) {
+		return parseJSONFeed(body)
+	}
+
+	root, err := xmlRootElement(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case root.Local == "rss":
+		return parseRSS(body)
+	case root.Local == "feed" && root.Space == atomNamespace:
+		return parseAtom(body)
+	case root.Local == "RDF" && root.Space == rdfNamespace:
+		return parseRDF(body)
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+}
+
+// xmlRootElement returns the name of the first element in an XML document.
+func xmlRootElement(body []byte) (xml.Name, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return xml.Name{}, fmt.Errorf("failed to parse feed: no root element")
+			}
+			return xml.Name{}, fmt.Errorf("failed to parse feed: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+func parseRSS(body []byte) (*Feed, error) {
+	var rss RSSFeed
+	if err := xml.Unmarshal(body, &rss); err != nil {
 		return nil, fmt.Errorf("failed to parse RSS feed: %w", err)
 	}
-	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
-	for i := range feed.Channel.Item {
-		item := &feed.Channel.Item[i]
-		item.Title = html.UnescapeString(item.Title)
-		item.Description = html.UnescapeString(item.Description)
+
+	feed := &Feed{
+		Title:       html.UnescapeString(rss.Channel.Title),
+		Link:        rss.Channel.Link,
+		Description: rss.Channel.Description,
+		UpdateInterval: max(
+			ttlInterval(rss.Channel.TTL),
+			syndicationInterval(rss.Channel.UpdatePeriod, rss.Channel.UpdateFrequency),
+		),
+		Items: make([]FeedItem, 0, len(rss.Channel.Item)),
+	}
+	for _, item := range rss.Channel.Item {
+		guid := strings.TrimSpace(item.GUID.Value)
+
+		// A guid is a permalink unless it says otherwise, so it can
+		// stand in for a missing <link>.
+		link := strings.TrimSpace(item.Link)
+		if link == "" && guid != "" && item.GUID.IsPermaLink != "false" {
+			link = guid
+		}
+
+		feed.Items = append(feed.Items, FeedItem{
+			ID:          guid,
+			Title:       html.UnescapeString(item.Title),
+			Link:        link,
+			RawLink:     item.Link,
+			Description: html.UnescapeString(item.Description),
+			PubDate:     item.PubDate,
+			Authors:     rssAuthors(item),
+		})
+	}
+
+	return feed, nil
+}
+
+// rssAuthors prefers dc:creator, which holds names, over <author>, which
+// RSS 2.0 defines as an email address optionally followed by the name in
+// parentheses.
+func rssAuthors(item RSSItem) []string {
+	if authors := nonEmpty(item.Creator); len(authors) > 0 {
+		return authors
 	}
+	author := strings.TrimSpace(item.Author)
+	if open := strings.Index(author, "("); open >= 0 && strings.HasSuffix(author, ")") {
+		if name := strings.TrimSpace(author[open+1 : len(author)-1]); name != "" {
+			return []string{name}
+		}
+	}
+	return nonEmpty([]string{author})
+}
 
-	return &feed, nil
+// nonEmpty trims names and drops the empty ones.
+func nonEmpty(names []string) []string {
+	var result []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			result = append(result, name)
+		}
+	}
+	return result
 }
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseFeed(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		title       string
+		items       []FeedItem
+	}{
+		{
+			name:        "RSS 2.0",
+			contentType: "application/rss+xml",
+			body: `<?xml version="1.0"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel>
+    <title>Tom &amp;amp; Jerry</title>
+    <item>
+      <title>Linked</title>
+      <link>
+        https://example.com/linked
+      </link>
+      <guid isPermaLink="false">post-1</guid>
+      <pubDate>Mon, 01 Jan 2024 10:00:00 +0000</pubDate>
+      <author>ann@example.com (Ann)</author>
+    </item>
+    <item>
+      <title>Permalink guid</title>
+      <guid>https://example.com/permalink</guid>
+      <dc:creator>Bo</dc:creator>
+    </item>
+    <item>
+      <title>Opaque guid</title>
+      <guid isPermaLink="false">post-3</guid>
+    </item>
+  </channel>
+</rss>`,
+			title: "Tom & Jerry",
+			items: []FeedItem{
+				{
+					ID:      "post-1",
+					Title:   "Linked",
+					Link:    "https://example.com/linked",
+					RawLink: "\n        https://example.com/linked\n      ",
+					PubDate: "Mon, 01 Jan 2024 10:00:00 +0000",
+					Authors: []string{"Ann"},
+				},
+				{
+					ID:      "https://example.com/permalink",
+					Title:   "Permalink guid",
+					Link:    "https://example.com/permalink",
+					Authors: []string{"Bo"},
+				},
+				{
+					ID:    "post-3",
+					Title: "Opaque guid",
+				},
+			},
+		},
+		{
+			name:        "Atom",
+			contentType: "application/atom+xml",
+			body: `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="html">Dev &amp;amp; Ops</title>
+  <author><name>Feed Author</name></author>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title>HTML summary</title>
+    <link rel="self" href="https://example.com/1.atom"/>
+    <link rel="alternate" href="https://example.com/1"/>
+    <published>2024-01-02T00:00:00Z</published>
+    <updated>2024-01-03T00:00:00Z</updated>
+    <summary type="html">&lt;p&gt;Hello&lt;/p&gt;</summary>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml">XHTML <b>title</b></div></title>
+    <link href="https://example.com/2"/>
+    <updated>2024-01-04T00:00:00Z</updated>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div></content>
+    <author><name>Dee</name></author>
+  </entry>
+</feed>`,
+			title: "Dev & Ops",
+			items: []FeedItem{
+				{
+					ID:          "urn:uuid:1",
+					Title:       "HTML summary",
+					Link:        "https://example.com/1",
+					RawLink:     "https://example.com/1",
+					Description: "<p>Hello</p>",
+					PubDate:     "2024-01-02T00:00:00Z",
+					Authors:     []string{"Feed Author"},
+				},
+				{
+					ID:          "urn:uuid:2",
+					Title:       `<div xmlns="http://www.w3.org/1999/xhtml">XHTML <b>title</b></div>`,
+					Link:        "https://example.com/2",
+					RawLink:     "https://example.com/2",
+					Description: `<div xmlns="http://www.w3.org/1999/xhtml"><p>Body</p></div>`,
+					PubDate:     "2024-01-04T00:00:00Z",
+					Authors:     []string{"Dee"},
+				},
+			},
+		},
+		{
+			name:        "RSS 1.0",
+			contentType: "application/rdf+xml",
+			body: `<?xml version="1.0"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+         xmlns="http://purl.org/rss/1.0/"
+         xmlns:dc="http://purl.org/dc/elements/1.1/">
+  <channel rdf:about="https://example.com/">
+    <title>RDF</title>
+    <items>
+      <rdf:Seq>
+        <rdf:li resource="https://example.com/a"/>
+        <rdf:li resource="https://example.com/b"/>
+      </rdf:Seq>
+    </items>
+  </channel>
+  <item rdf:about="https://example.com/a">
+    <title>A</title>
+    <link>https://example.com/a?from=rss</link>
+    <dc:date>2024-01-05</dc:date>
+    <dc:creator>Eve</dc:creator>
+  </item>
+  <item rdf:about="https://example.com/b">
+    <title>B</title>
+    <description>About B</description>
+  </item>
+</rdf:RDF>`,
+			title: "RDF",
+			items: []FeedItem{
+				{
+					ID:      "https://example.com/a",
+					Title:   "A",
+					Link:    "https://example.com/a?from=rss",
+					RawLink: "https://example.com/a?from=rss",
+					PubDate: "2024-01-05",
+					Authors: []string{"Eve"},
+				},
+				{
+					ID:          "https://example.com/b",
+					Title:       "B",
+					Link:        "https://example.com/b",
+					RawLink:     "https://example.com/b",
+					Description: "About B",
+				},
+			},
+		},
+		{
+			name:        "JSON Feed served as text/plain",
+			contentType: "text/plain; charset=utf-8",
+			body: `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "JSON",
+  "authors": [{"name": "Feed Author"}],
+  "items": [
+    {
+      "id": 42,
+      "url": "https://example.com/42",
+      "title": "Numeric id",
+      "content_html": "<p>Hi</p>",
+      "content_text": "Hi",
+      "date_published": "2024-01-06T00:00:00Z"
+    },
+    {
+      "id": "b",
+      "external_url": "https://elsewhere.example/b",
+      "content_text": "Text only",
+      "date_modified": "2024-01-07T00:00:00Z",
+      "author": {"name": "Fay"}
+    }
+  ]
+}`,
+			title: "JSON",
+			items: []FeedItem{
+				{
+					ID:          "42",
+					Title:       "Numeric id",
+					Link:        "https://example.com/42",
+					RawLink:     "https://example.com/42",
+					Description: "<p>Hi</p>",
+					PubDate:     "2024-01-06T00:00:00Z",
+					Authors:     []string{"Feed Author"},
+				},
+				{
+					ID:          "b",
+					Link:        "https://elsewhere.example/b",
+					RawLink:     "https://elsewhere.example/b",
+					Description: "Text only",
+					PubDate:     "2024-01-07T00:00:00Z",
+					Authors:     []string{"Fay"},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := parseFeed(tt.contentType, []byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseFeed: %v", err)
+			}
+			if feed.Title != tt.title {
+				t.Errorf("title = %q, want %q", feed.Title, tt.title)
+			}
+			if len(feed.Items) != len(tt.items) {
+				t.Fatalf("got %d items, want %d", len(feed.Items), len(tt.items))
+			}
+			for i, want := range tt.items {
+				if got := feed.Items[i]; !reflect.DeepEqual(got, want) {
+					t.Errorf("item %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{name: "unknown root element", body: `<html><body>Not a feed</body></html>`},
+		{name: "Atom element outside the Atom namespace", body: `<feed><entry/></feed>`},
+		{name: "empty document", body: ``},
+		{name: "JSON that isn't a JSON Feed", body: `{"version": "2.0"}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseFeed("", []byte(tt.body)); err == nil {
+				t.Errorf("parseFeed(%q) succeeded", tt.body)
+			}
+		})
+	}
+}
+
+func TestFetchFeedRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(`<rss version="2.0"><channel><title>Feed</title></channel></rss>`))
+	})
+	redirect := func(path, to string, code int) {
+		mux.Handle(path, http.RedirectHandler(to, code))
+	}
+	redirect("/moved", "/feed.xml", http.StatusMovedPermanently)
+	redirect("/permanent", "/moved", http.StatusPermanentRedirect)
+	redirect("/found", "/feed.xml", http.StatusFound)
+	redirect("/moved-then-found", "/found", http.StatusMovedPermanently)
+	redirect("/found-then-moved", "/moved", http.StatusFound)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		path    string
+		movedTo string
+	}{
+		{name: "no redirect", path: "/feed.xml", movedTo: ""},
+		{name: "301", path: "/moved", movedTo: "/feed.xml"},
+		{name: "308 then 301", path: "/permanent", movedTo: "/feed.xml"},
+		{name: "302", path: "/found", movedTo: ""},
+		{name: "301 then 302", path: "/moved-then-found", movedTo: ""},
+		{name: "302 then 301", path: "/found-then-moved", movedTo: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := fetchFeed(context.Background(), srv.URL+tt.path, feedCache{})
+			if err != nil {
+				t.Fatalf("fetchFeed: %v", err)
+			}
+			want := ""
+			if tt.movedTo != "" {
+				want = srv.URL + tt.movedTo
+			}
+			if result.MovedTo != want {
+				t.Errorf("fetchFeed(%q) moved to %q, want %q", tt.path, result.MovedTo, want)
+			}
+		})
+	}
+}
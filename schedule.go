@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultFetchInterval is used for feeds we know nothing about yet.
+	defaultFetchInterval = time.Hour
+	// Adaptive intervals are kept within these bounds. An explicit
+	// per-feed interval is only held to minFetchInterval.
+	minFetchInterval = 5 * time.Minute
+	maxFetchInterval = 24 * time.Hour
+	// maxRetryInterval caps the exponential backoff of failing feeds.
+	maxRetryInterval = 24 * time.Hour
+)
+
+// fetchInterval decides how long to wait before fetching a feed again.
+// An explicit per-feed interval always wins. Otherwise the feed is polled
+// at twice the rate it has been posting at, but never more often than the
+// publisher asked for via <ttl> or sy:updatePeriod.
+func fetchInterval(override, hint, averageGap time.Duration) time.Duration {
+	if override > 0 {
+		return max(override, minFetchInterval)
+	}
+
+	interval := defaultFetchInterval
+	if averageGap > 0 {
+		interval = averageGap / 2
+	}
+	if hint > interval {
+		interval = hint
+	}
+	return min(max(interval, minFetchInterval), maxFetchInterval)
+}
+
+// retryInterval backs off exponentially from the feed's regular interval
+// for each consecutive failure.
+func retryInterval(interval time.Duration, failures int) time.Duration {
+	for i := 1; i < failures && interval < maxRetryInterval; i++ {
+		interval *= 2
+	}
+	return min(interval, maxRetryInterval)
+}
+
+// ttlInterval converts an RSS 2.0 <ttl>, given in minutes.
+func ttlInterval(ttl string) time.Duration {
+	minutes, err := strconv.Atoi(strings.TrimSpace(ttl))
+	if err != nil || minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+// syndicationInterval converts the RSS syndication module's
+// sy:updatePeriod and sy:updateFrequency, which mean "updateFrequency
+// times per updatePeriod".
+func syndicationInterval(period, frequency string) time.Duration {
+	var length time.Duration
+	switch strings.TrimSpace(period) {
+	case "hourly":
+		length = time.Hour
+	case "daily":
+		length = 24 * time.Hour
+	case "weekly":
+		length = 7 * 24 * time.Hour
+	case "monthly":
+		length = 30 * 24 * time.Hour
+	case "yearly":
+		length = 365 * 24 * time.Hour
+	default:
+		return 0
+	}
+
+	times := 1
+	if f, err := strconv.Atoi(strings.TrimSpace(frequency)); err == nil && f > 0 {
+		times = f
+	}
+	return length / time.Duration(times)
+}
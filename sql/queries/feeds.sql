@@ -1,28 +1,39 @@
 -- name: CreateFeed :one
-INSERT INTO feeds (id, created_at, updated_at, name, url, user_id)
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, fetch_interval_seconds)
 VALUES (
     $1,
     $2,
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7
 )
 RETURNING *;
 
 -- name: GetFeeds :many
 SELECT
+    feeds.id AS feed_id,
     feeds.name AS feed_name,
     feeds.url AS feed_url,
-    users.name AS user_name
+    users.name AS user_name,
+    feeds.created_at,
+    feeds.last_fetched_at,
+    feeds.next_fetch_at,
+    feeds.disabled_at
 FROM feeds
 JOIN users ON feeds.user_id = users.id
 ORDER BY feeds.created_at;
 
 -- name: GetFeedByURL :one
-SELECT id, name, url, created_at, updated_at, user_id
+-- Also finds feeds that have moved away from url. A feed currently at
+-- url wins over one that used to be there.
+SELECT feeds.id, feeds.name, feeds.url, feeds.created_at, feeds.updated_at, feeds.user_id
 FROM feeds
-WHERE url = $1;
+WHERE feeds.url = $1
+   OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = $1)
+ORDER BY feeds.url = $1 DESC
+LIMIT 1;
 
 
 -- name: CreateFeedFollow :one
@@ -57,7 +68,19 @@ SELECT
     feed_follows.user_id,
     feed_follows.feed_id,
     users.name AS user_name,
-    feeds.name AS feed_name
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    (
+        SELECT COUNT(*)
+        FROM posts
+        WHERE posts.feed_id = feed_follows.feed_id
+          AND NOT EXISTS (
+              SELECT 1
+              FROM post_reads
+              WHERE post_reads.post_id = posts.id
+                AND post_reads.user_id = feed_follows.user_id
+          )
+    ) AS unread_count
 FROM feed_follows
 JOIN users ON users.id = feed_follows.user_id
 JOIN feeds ON feeds.id = feed_follows.feed_id
@@ -70,22 +93,222 @@ DELETE FROM feed_follows
 WHERE user_id = $1
   AND feed_id = $2;
 
--- name: MarkFeedFetched :exec
+-- name: ClaimFeedsToFetch :many
+-- Locks and leases a batch of due feeds in one statement, so concurrent
+-- agg processes never pick the same feed. Feeds whose lease has run out
+-- (e.g. the claiming process crashed) can be claimed again. Disabled
+-- feeds are skipped.
 UPDATE feeds
 SET
-    last_fetched_at = NOW(),
-    updated_at = NOW()
-WHERE id = $1;
+    updated_at = NOW(),
+    claimed_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::int)
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (claimed_until IS NULL OR claimed_until < NOW())
+      AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds;
 
--- name: GetNextFeedToFetch :one
-SELECT
+-- name: ClaimFeed :one
+-- Claims a single feed regardless of its schedule or disabled state, for
+-- explicit refreshes. Fails with no rows if another process holds it.
+UPDATE feeds
+SET
+    updated_at = NOW(),
+    claimed_until = NOW() + make_interval(secs => sqlc.arg(lease_seconds)::int)
+WHERE id = sqlc.arg(id)
+  AND (claimed_until IS NULL OR claimed_until < NOW())
+RETURNING
     id,
     name,
     url,
     user_id,
     last_fetched_at,
     created_at,
-    updated_at
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds;
+
+-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_until = NULL
+WHERE id = $1;
+
+-- name: RecordFeedSuccess :exec
+-- A successful fetch also re-enables a disabled feed, as happens when
+-- one is fetched explicitly with agg --feed.
+UPDATE feeds
+SET
+    last_fetched_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => sqlc.arg(next_fetch_in_seconds)::int),
+    etag = sqlc.arg(etag),
+    last_modified = sqlc.arg(last_modified),
+    hinted_interval_seconds = sqlc.arg(hinted_interval_seconds),
+    consecutive_failures = 0,
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id);
+
+-- name: RecordFeedFailure :one
+-- Disables the feed once it has failed max_failures times in a row;
+-- a max_failures of 0 never disables it.
+UPDATE feeds
+SET
+    last_error = sqlc.arg(last_error)::text,
+    last_error_at = NOW(),
+    next_fetch_at = NOW() + make_interval(secs => sqlc.arg(next_fetch_in_seconds)::int),
+    consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE
+        WHEN sqlc.arg(max_failures)::int > 0
+             AND consecutive_failures + 1 >= sqlc.arg(max_failures)::int
+        THEN NOW()
+        ELSE disabled_at
+    END,
+    disabled_reason = CASE
+        WHEN sqlc.arg(max_failures)::int > 0
+             AND consecutive_failures + 1 >= sqlc.arg(max_failures)::int
+        THEN sqlc.arg(disabled_reason)::text
+        ELSE disabled_reason
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures, disabled_at;
+
+-- name: SetFeedInterval :exec
+-- Clearing next_fetch_at makes the feed due immediately, so the new
+-- interval takes effect from its next fetch.
+UPDATE feeds
+SET
+    fetch_interval_seconds = $2,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeedsWithErrors :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.last_error,
+    feeds.last_error_at,
+    feeds.consecutive_failures,
+    feeds.disabled_at,
+    feeds.disabled_reason
 FROM feeds
-ORDER BY last_fetched_at ASC NULLS FIRST
-LIMIT 1;
+JOIN users ON feeds.user_id = users.id
+WHERE feeds.consecutive_failures > 0
+   OR feeds.disabled_at IS NOT NULL
+ORDER BY feeds.disabled_at ASC NULLS LAST, feeds.consecutive_failures DESC;
+
+-- name: EnableFeed :exec
+-- Clears the feed's failures and makes it due right away, rather than
+-- at the retry time its last failure set.
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+    id,
+    feed_id,
+    started_at,
+    finished_at,
+    status,
+    items,
+    inserted,
+    updated,
+    duplicates,
+    failed,
+    error
+)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+);
+
+-- name: CountFeedDependents :one
+SELECT
+    (SELECT COUNT(*) FROM posts WHERE posts.feed_id = $1) AS post_count,
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = $1) AS follow_count;
+
+-- name: DeleteFeed :execrows
+-- Deletes a feed owned by user_id. Its posts, follows and fetch history
+-- go with it; saved copies of its posts are kept.
+DELETE FROM feeds
+WHERE id = $1
+  AND user_id = $2;
+
+-- name: RenameFeed :exec
+UPDATE feeds
+SET
+    name = $2,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetFeedURL :exec
+-- Moves a feed to a new URL, keeping its posts and follows. The cache
+-- headers belong to the old URL, so they are dropped and the feed is
+-- fetched again on the next run.
+UPDATE feeds
+SET
+    url = $2,
+    etag = NULL,
+    last_modified = NULL,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: MoveFeedURL :execrows
+-- Moves a feed that redirected permanently from old_url to new_url and
+-- keeps old_url as an alias. Nothing happens if another feed is already
+-- at new_url.
+WITH moved AS (
+    UPDATE feeds
+    SET
+        url = sqlc.arg(new_url),
+        updated_at = NOW()
+    WHERE feeds.id = sqlc.arg(id)
+      AND feeds.url = sqlc.arg(old_url)
+      AND NOT EXISTS (
+          SELECT 1
+          FROM feeds AS other
+          WHERE other.url = sqlc.arg(new_url)
+      )
+    RETURNING feeds.id
+),
+returned AS (
+    DELETE FROM feed_aliases
+    WHERE feed_aliases.url = sqlc.arg(new_url)
+      AND feed_aliases.feed_id IN (SELECT id FROM moved)
+)
+INSERT INTO feed_aliases (url, created_at, feed_id)
+SELECT sqlc.arg(old_url), NOW(), moved.id
+FROM moved
+ON CONFLICT (url) DO UPDATE
+SET feed_id = EXCLUDED.feed_id;
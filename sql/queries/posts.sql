@@ -1,26 +1,224 @@
--- name: CreatePost :one
-INSERT INTO posts (
+-- name: UpsertPost :one
+-- Inserts a new post, or refreshes a known one whose content has changed.
+-- Always returns one row counting what happened: inserted = 1 for a new
+-- post, updated = 1 for a changed one, both 0 when the stored post is
+-- already up to date. Rows saved before content hashes existed only have
+-- their hash filled in.
+WITH upserted AS (
+    INSERT INTO posts (
+        id,
+        created_at,
+        updated_at,
+        title,
+        url,
+        description,
+        published_at,
+        feed_id,
+        guid,
+        content_hash,
+        authors
+    )
+    VALUES (
+        $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11
+    )
+    ON CONFLICT (feed_id, guid) DO UPDATE
+    SET
+        title = EXCLUDED.title,
+        description = EXCLUDED.description,
+        published_at = EXCLUDED.published_at,
+        content_hash = EXCLUDED.content_hash,
+        authors = EXCLUDED.authors,
+        updated_at = CASE
+            WHEN posts.content_hash IS NULL THEN posts.updated_at
+            ELSE EXCLUDED.updated_at
+        END
+    WHERE posts.content_hash IS DISTINCT FROM EXCLUDED.content_hash
+    RETURNING id
+)
+SELECT
+    COUNT(*) FILTER (WHERE id = $1) AS inserted,
+    COUNT(*) FILTER (WHERE id <> $1) AS updated
+FROM upserted;
+
+-- name: CreatePostRevision :exec
+-- Keeps the stored version of a post before UpsertPost overwrites it with
+-- changed content. Does nothing if the post is new or unchanged.
+INSERT INTO post_revisions (
     id,
+    post_id,
     created_at,
-    updated_at,
     title,
-    url,
     description,
     published_at,
-    feed_id
-)
-VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8
+    content_hash
 )
-ON CONFLICT (url) DO NOTHING
-RETURNING *;
+SELECT
+    sqlc.arg(id)::uuid,
+    posts.id,
+    NOW(),
+    posts.title,
+    posts.description,
+    posts.published_at,
+    posts.content_hash
+FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = sqlc.arg(guid)
+  AND posts.content_hash <> sqlc.arg(content_hash)::text;
+
+-- name: GetPost :one
+SELECT *
+FROM posts
+WHERE id = $1;
+
+-- name: GetPostRevisions :many
+SELECT *
+FROM post_revisions
+WHERE post_id = $1
+ORDER BY created_at ASC;
+
+-- name: AdoptLegacyPost :exec
+-- Posts saved before GUIDs were tracked carry the fallback identity. When
+-- their item shows up with a real GUID, switch the row over instead of
+-- inserting the story a second time.
+UPDATE posts
+SET
+    guid = sqlc.arg(guid),
+    updated_at = NOW()
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = sqlc.arg(fallback_guid)
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS adopted
+      WHERE adopted.feed_id = sqlc.arg(feed_id)
+        AND adopted.guid = sqlc.arg(guid)
+  );
 
 -- name: GetPostsForUser :many
-SELECT 
-    posts.*
+-- Lists the posts of the feeds a user follows. sort is 'published'
+-- (newest first, undated posts by when they were fetched), 'fetched'
+-- (newest fetched first) or 'feed' (by feed name, then as 'published').
+-- sort_time is the timestamp a row is ordered by; together with feed_name
+-- and id it forms the keyset cursor, given as the after_* arguments.
+-- An empty feeds array means all followed feeds.
+WITH user_posts AS (
+    SELECT
+        posts.id,
+        posts.created_at,
+        posts.updated_at,
+        posts.title,
+        posts.url,
+        posts.description,
+        posts.published_at,
+        posts.feed_id,
+        posts.authors,
+        feeds.name AS feed_name,
+        post_reads.read_at,
+        CASE
+            WHEN sqlc.arg(sort)::text = 'fetched' THEN posts.created_at
+            ELSE COALESCE(posts.published_at, posts.created_at)
+        END::timestamp AS sort_time
+    FROM posts
+    JOIN feeds ON posts.feed_id = feeds.id
+    JOIN feed_follows ON feed_follows.feed_id = feeds.id
+    LEFT JOIN post_reads
+        ON post_reads.post_id = posts.id
+       AND post_reads.user_id = feed_follows.user_id
+    WHERE feed_follows.user_id = sqlc.arg(user_id)
+      AND (NOT sqlc.arg(unread_only)::bool OR post_reads.read_at IS NULL)
+      AND (
+          cardinality(sqlc.arg(feeds)::text[]) = 0
+          OR feeds.name = ANY(sqlc.arg(feeds)::text[])
+          OR feeds.url = ANY(sqlc.arg(feeds)::text[])
+      )
+      AND (sqlc.narg(since)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+      AND (sqlc.narg(until)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+)
+SELECT *
+FROM user_posts
+WHERE sqlc.narg(after_id)::uuid IS NULL
+   OR (
+       sqlc.arg(sort)::text = 'feed'
+       AND (
+           feed_name > sqlc.narg(after_feed)::text
+           OR (
+               feed_name = sqlc.narg(after_feed)::text
+               AND (sort_time, id) < (sqlc.narg(after_time)::timestamp, sqlc.narg(after_id)::uuid)
+           )
+       )
+   )
+   OR (
+       sqlc.arg(sort)::text <> 'feed'
+       AND (sort_time, id) < (sqlc.narg(after_time)::timestamp, sqlc.narg(after_id)::uuid)
+   )
+ORDER BY
+    CASE WHEN sqlc.arg(sort)::text = 'feed' THEN feed_name END ASC,
+    sort_time DESC,
+    id DESC
+OFFSET sqlc.arg('offset')
+LIMIT sqlc.arg('limit');
+
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = $1
+  AND post_id = $2;
+
+
+-- name: GetFeedPostingStats :one
+-- Average gap between the feed's most recent posts, used to adapt how
+-- often it is polled. 0 when there are fewer than two dated posts.
+SELECT
+    COALESCE(
+        EXTRACT(EPOCH FROM MAX(recent.published_at) - MIN(recent.published_at))
+            / NULLIF(COUNT(*) - 1, 0),
+        0
+    )::int AS average_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = $1
+      AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent;
+
+-- name: SearchPostsForUser :many
+-- Full-text search over the posts of the feeds a user follows. feed
+-- matches either a feed's name or its URL; feed, since and until are
+-- optional. Undated posts are filtered by when they were fetched.
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    posts.created_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, websearch_to_tsquery('english', sqlc.arg(query)::text)) AS rank,
+    ts_headline(
+        'english',
+        regexp_replace(coalesce(posts.description, posts.title), '<[^>]*>', ' ', 'g'),
+        websearch_to_tsquery('english', sqlc.arg(query)::text),
+        'StartSel=**, StopSel=**, MaxFragments=2, MinWords=5, MaxWords=20'
+    )::text AS snippet
 FROM posts
 JOIN feeds ON posts.feed_id = feeds.id
 JOIN feed_follows ON feed_follows.feed_id = feeds.id
-WHERE feed_follows.user_id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2;
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND posts.search_vector @@ websearch_to_tsquery('english', sqlc.arg(query)::text)
+  AND (sqlc.narg(feed)::text IS NULL OR feeds.name = sqlc.narg(feed) OR feeds.url = sqlc.narg(feed))
+  AND (sqlc.narg(since)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+  AND (sqlc.narg(until)::timestamp IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+ORDER BY rank DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT sqlc.arg('limit');
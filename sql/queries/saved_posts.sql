@@ -0,0 +1,50 @@
+-- name: CreateSavedPost :one
+-- Saving an already saved post keeps it, replacing the note if a new one
+-- is given.
+INSERT INTO saved_posts (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    post_id,
+    note,
+    title,
+    url,
+    description,
+    published_at,
+    feed_name
+)
+SELECT
+    sqlc.arg(id)::uuid,
+    NOW(),
+    NOW(),
+    sqlc.arg(user_id)::uuid,
+    posts.id,
+    sqlc.narg(note)::text,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    feeds.name
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id = sqlc.arg(post_id)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET
+    note = COALESCE(EXCLUDED.note, saved_posts.note),
+    updated_at = NOW()
+RETURNING *;
+
+-- name: DeleteSavedPost :execrows
+-- Accepts either the post's id or, for posts that no longer exist, the
+-- id of the saved copy.
+DELETE FROM saved_posts
+WHERE user_id = sqlc.arg(user_id)
+  AND (post_id = sqlc.arg(id)::uuid OR id = sqlc.arg(id)::uuid);
+
+-- name: GetSavedPostsForUser :many
+SELECT *
+FROM saved_posts
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
@@ -1,4 +1,4 @@
--- +goose up 
+-- +goose Up
 CREATE TABLE posts (
     id UUID PRIMARY KEY,
     created_at TIMESTAMP NOT NULL,
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Posts are identified per feed by the item's GUID. Rows from before
+-- this migration get the same fallback identity gator uses for items
+-- without one: the SHA-256 of link and title.
+ALTER TABLE posts
+ADD COLUMN guid TEXT;
+
+UPDATE posts
+SET guid = encode(sha256(convert_to(url || E'\n' || title, 'UTF8')), 'hex');
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL,
+DROP CONSTRAINT posts_url_key,
+ADD CONSTRAINT posts_feed_guid_unique UNIQUE (feed_id, guid);
+
+-- +goose Down
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_guid_unique;
+
+-- The same URL may now appear under several feeds; keep the oldest copy.
+DELETE FROM posts a
+USING posts b
+WHERE a.url = b.url
+  AND (a.created_at, a.id) > (b.created_at, b.id);
+
+ALTER TABLE posts
+ADD CONSTRAINT posts_url_key UNIQUE (url),
+DROP COLUMN guid;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- content_hash fingerprints the fields a publisher may edit after the
+-- fact. Existing rows start without one and get it on their next fetch.
+ALTER TABLE posts
+ADD COLUMN content_hash TEXT;
+
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    content_hash TEXT NOT NULL
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id, created_at);
+
+-- +goose Down
+DROP TABLE post_revisions;
+
+ALTER TABLE posts
+DROP COLUMN content_hash;
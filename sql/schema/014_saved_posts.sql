@@ -0,0 +1,22 @@
+-- +goose Up
+-- Saved posts keep a copy of the post, so they outlive it: deleting a
+-- feed cascades to its posts, which only detaches the saved copy.
+CREATE TABLE saved_posts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID REFERENCES posts(id) ON DELETE SET NULL,
+    note TEXT,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_name TEXT NOT NULL,
+
+    CONSTRAINT saved_posts_user_post_unique
+        UNIQUE (user_id, post_id)
+);
+
+-- +goose Down
+DROP TABLE saved_posts;
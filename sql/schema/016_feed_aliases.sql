@@ -0,0 +1,17 @@
+-- +goose Up
+-- URLs a feed was known by before it moved permanently, so that the old
+-- URL keeps resolving to the feed.
+CREATE TABLE feed_aliases (
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+ALTER TABLE feeds
+ADD COLUMN disabled_reason TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN disabled_reason;
+
+DROP TABLE feed_aliases;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- The names of a post's authors, joined with ", ", or NULL if the feed
+-- doesn't name any.
+ALTER TABLE posts
+ADD COLUMN authors TEXT;
+
+-- +goose Down
+ALTER TABLE posts
+DROP COLUMN authors;
@@ -0,0 +1,301 @@
+-- name: CreateFeed :one
+INSERT INTO feeds (id, created_at, updated_at, name, url, user_id, fetch_interval_seconds)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetFeeds :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.created_at,
+    feeds.last_fetched_at,
+    feeds.next_fetch_at,
+    feeds.disabled_at
+FROM feeds
+JOIN users ON feeds.user_id = users.id
+ORDER BY feeds.created_at;
+
+-- name: GetFeedByURL :one
+-- Also finds feeds that have moved away from url. A feed currently at
+-- url wins over one that used to be there. url is joined in as a column
+-- because sqlc can't bind arguments in ORDER BY.
+SELECT feeds.id, feeds.name, feeds.url, feeds.created_at, feeds.updated_at, feeds.user_id
+FROM feeds
+JOIN (SELECT CAST(sqlc.arg(url) AS TEXT) AS url) AS lookup
+WHERE feeds.url = lookup.url
+   OR feeds.id = (SELECT feed_id FROM feed_aliases WHERE feed_aliases.url = lookup.url)
+ORDER BY feeds.url = lookup.url DESC
+LIMIT 1;
+
+-- name: CreateFeedFollow :one
+INSERT INTO feed_follows (id, created_at, updated_at, user_id, feed_id)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetFeedFollow :one
+-- SQLite has no INSERT in WITH, so the names that CreateFeedFollow
+-- returns on PostgreSQL are looked up separately.
+SELECT
+    feed_follows.id,
+    feed_follows.created_at,
+    feed_follows.updated_at,
+    feed_follows.user_id,
+    feed_follows.feed_id,
+    users.name AS user_name,
+    feeds.name AS feed_name
+FROM feed_follows
+JOIN users ON users.id = feed_follows.user_id
+JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.id = ?;
+
+-- name: GetFeedFollowsForUser :many
+SELECT
+    feed_follows.id,
+    feed_follows.created_at,
+    feed_follows.updated_at,
+    feed_follows.user_id,
+    feed_follows.feed_id,
+    users.name AS user_name,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    (
+        SELECT COUNT(*)
+        FROM posts
+        WHERE posts.feed_id = feed_follows.feed_id
+          AND NOT EXISTS (
+              SELECT 1
+              FROM post_reads
+              WHERE post_reads.post_id = posts.id
+                AND post_reads.user_id = feed_follows.user_id
+          )
+    ) AS unread_count
+FROM feed_follows
+JOIN users ON users.id = feed_follows.user_id
+JOIN feeds ON feeds.id = feed_follows.feed_id
+WHERE feed_follows.user_id = ?
+ORDER BY feed_follows.created_at DESC;
+
+-- name: DeleteFeedFollow :exec
+DELETE FROM feed_follows
+WHERE user_id = ?
+  AND feed_id = ?;
+
+-- name: ClaimFeedsToFetch :many
+-- Leases a batch of due feeds in one statement. SQLite runs one writer
+-- at a time, so concurrent agg processes never pick the same feed.
+-- Feeds whose lease has run out can be claimed again. Disabled feeds are
+-- skipped.
+UPDATE feeds
+SET
+    updated_at = datetime('now'),
+    claimed_until = datetime('now', '+' || CAST(sqlc.arg(lease_seconds) AS INTEGER) || ' seconds')
+WHERE id IN (
+    SELECT id
+    FROM feeds
+    WHERE disabled_at IS NULL
+      AND (claimed_until IS NULL OR claimed_until < datetime('now'))
+      AND (next_fetch_at IS NULL OR next_fetch_at <= datetime('now'))
+    ORDER BY next_fetch_at ASC NULLS FIRST, last_fetched_at ASC NULLS FIRST
+    LIMIT sqlc.arg(batch_size)
+)
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds;
+
+-- name: ClaimFeed :one
+-- Claims a single feed regardless of its schedule or disabled state, for
+-- explicit refreshes. Fails with no rows if another process holds it.
+UPDATE feeds
+SET
+    updated_at = datetime('now'),
+    claimed_until = datetime('now', '+' || CAST(sqlc.arg(lease_seconds) AS INTEGER) || ' seconds')
+WHERE id = sqlc.arg(id)
+  AND (claimed_until IS NULL OR claimed_until < datetime('now'))
+RETURNING
+    id,
+    name,
+    url,
+    user_id,
+    last_fetched_at,
+    created_at,
+    updated_at,
+    etag,
+    last_modified,
+    consecutive_failures,
+    fetch_interval_seconds,
+    hinted_interval_seconds;
+
+-- name: ReleaseFeedClaim :exec
+UPDATE feeds
+SET claimed_until = NULL
+WHERE id = ?;
+
+-- name: RecordFeedSuccess :exec
+-- A successful fetch also re-enables a disabled feed, as happens when
+-- one is fetched explicitly with agg --feed.
+UPDATE feeds
+SET
+    last_fetched_at = datetime('now'),
+    next_fetch_at = datetime('now', '+' || CAST(sqlc.arg(next_fetch_in_seconds) AS INTEGER) || ' seconds'),
+    etag = sqlc.arg(etag),
+    last_modified = sqlc.arg(last_modified),
+    hinted_interval_seconds = sqlc.arg(hinted_interval_seconds),
+    consecutive_failures = 0,
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    updated_at = datetime('now')
+WHERE id = sqlc.arg(id);
+
+-- name: RecordFeedFailure :one
+-- Disables the feed once it has failed max_failures times in a row;
+-- a max_failures of 0 never disables it.
+UPDATE feeds
+SET
+    last_error = CAST(sqlc.arg(last_error) AS TEXT),
+    last_error_at = datetime('now'),
+    next_fetch_at = datetime('now', '+' || CAST(sqlc.arg(next_fetch_in_seconds) AS INTEGER) || ' seconds'),
+    consecutive_failures = consecutive_failures + 1,
+    disabled_at = CASE
+        WHEN CAST(sqlc.arg(max_failures) AS INTEGER) > 0
+             AND consecutive_failures + 1 >= CAST(sqlc.arg(max_failures) AS INTEGER)
+        THEN datetime('now')
+        ELSE disabled_at
+    END,
+    disabled_reason = CASE
+        WHEN CAST(sqlc.arg(max_failures) AS INTEGER) > 0
+             AND consecutive_failures + 1 >= CAST(sqlc.arg(max_failures) AS INTEGER)
+        THEN CAST(sqlc.arg(disabled_reason) AS TEXT)
+        ELSE disabled_reason
+    END,
+    updated_at = datetime('now')
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures, disabled_at;
+
+-- name: SetFeedInterval :exec
+-- Clearing next_fetch_at makes the feed due immediately, so the new
+-- interval takes effect from its next fetch.
+UPDATE feeds
+SET
+    fetch_interval_seconds = sqlc.arg(fetch_interval_seconds),
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = sqlc.arg(id);
+
+-- name: GetFeedsWithErrors :many
+SELECT
+    feeds.id AS feed_id,
+    feeds.name AS feed_name,
+    feeds.url AS feed_url,
+    users.name AS user_name,
+    feeds.last_error,
+    feeds.last_error_at,
+    feeds.consecutive_failures,
+    feeds.disabled_at,
+    feeds.disabled_reason
+FROM feeds
+JOIN users ON feeds.user_id = users.id
+WHERE feeds.consecutive_failures > 0
+   OR feeds.disabled_at IS NOT NULL
+ORDER BY feeds.disabled_at ASC NULLS LAST, feeds.consecutive_failures DESC;
+
+-- name: EnableFeed :exec
+-- Clears the feed's failures and makes it due right away, rather than
+-- at the retry time its last failure set.
+UPDATE feeds
+SET
+    disabled_at = NULL,
+    disabled_reason = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = ?;
+
+-- name: CreateFeedFetch :exec
+INSERT INTO feed_fetches (
+    id,
+    feed_id,
+    started_at,
+    finished_at,
+    status,
+    items,
+    inserted,
+    updated,
+    duplicates,
+    failed,
+    error
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: CountFeedDependents :one
+SELECT
+    (SELECT COUNT(*) FROM posts WHERE posts.feed_id = sqlc.arg(feed_id)) AS post_count,
+    (SELECT COUNT(*) FROM feed_follows WHERE feed_follows.feed_id = sqlc.arg(feed_id)) AS follow_count;
+
+-- name: DeleteFeed :execrows
+-- Deletes a feed owned by user_id. Its posts, follows and fetch history
+-- go with it; saved copies of its posts are kept.
+DELETE FROM feeds
+WHERE id = ?
+  AND user_id = ?;
+
+-- name: RenameFeed :exec
+UPDATE feeds
+SET
+    name = sqlc.arg(name),
+    updated_at = datetime('now')
+WHERE id = sqlc.arg(id);
+
+-- name: SetFeedURL :exec
+-- Moves a feed to a new URL, keeping its posts and follows. The cache
+-- headers belong to the old URL, so they are dropped and the feed is
+-- fetched again on the next run.
+UPDATE feeds
+SET
+    url = sqlc.arg(url),
+    etag = NULL,
+    last_modified = NULL,
+    next_fetch_at = NULL,
+    updated_at = datetime('now')
+WHERE id = sqlc.arg(id);
+
+-- name: MoveFeedURL :execrows
+-- Moves a feed that redirected permanently from old_url to new_url.
+-- Nothing happens if another feed is already at new_url. SQLite has no
+-- DML in WITH, so the caller updates the aliases with DeleteFeedAlias
+-- and AddFeedAlias in the same transaction.
+UPDATE feeds
+SET
+    url = sqlc.arg(new_url),
+    updated_at = datetime('now')
+WHERE feeds.id = sqlc.arg(id)
+  AND feeds.url = sqlc.arg(old_url)
+  AND NOT EXISTS (
+      SELECT 1
+      FROM feeds AS other
+      WHERE other.url = sqlc.arg(new_url)
+  );
+
+-- name: DeleteFeedAlias :exec
+-- Drops the alias for a URL the feed has moved back to.
+DELETE FROM feed_aliases
+WHERE feed_aliases.url = sqlc.arg(url)
+  AND feed_aliases.feed_id = sqlc.arg(feed_id);
+
+-- name: AddFeedAlias :exec
+-- Keeps url pointing at a feed that has moved away from it.
+INSERT INTO feed_aliases (url, created_at, feed_id)
+VALUES (sqlc.arg(url), datetime('now'), sqlc.arg(feed_id))
+ON CONFLICT (url) DO UPDATE
+SET feed_id = excluded.feed_id;
@@ -0,0 +1,210 @@
+-- name: UpsertPost :one
+-- Inserts a new post, or refreshes a known one whose content has changed,
+-- and returns its id: the given id for a new post, the stored one for a
+-- changed one. Returns no rows when the stored post is already up to
+-- date. Rows saved before content hashes existed only have their hash
+-- filled in.
+INSERT INTO posts (
+    id,
+    created_at,
+    updated_at,
+    title,
+    url,
+    description,
+    published_at,
+    feed_id,
+    guid,
+    content_hash,
+    authors
+)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET
+    title = excluded.title,
+    description = excluded.description,
+    published_at = excluded.published_at,
+    content_hash = excluded.content_hash,
+    authors = excluded.authors,
+    updated_at = CASE
+        WHEN posts.content_hash IS NULL THEN posts.updated_at
+        ELSE excluded.updated_at
+    END
+WHERE posts.content_hash IS NOT excluded.content_hash
+RETURNING id;
+
+-- name: CreatePostRevision :exec
+-- Keeps the stored version of a post before UpsertPost overwrites it with
+-- changed content. Does nothing if the post is new or unchanged.
+INSERT INTO post_revisions (
+    id,
+    post_id,
+    created_at,
+    title,
+    description,
+    published_at,
+    content_hash
+)
+SELECT
+    sqlc.arg(id),
+    posts.id,
+    datetime('now'),
+    posts.title,
+    posts.description,
+    posts.published_at,
+    posts.content_hash
+FROM posts
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = sqlc.arg(guid)
+  AND posts.content_hash <> CAST(sqlc.arg(content_hash) AS TEXT);
+
+-- name: GetPost :one
+SELECT *
+FROM posts
+WHERE id = ?;
+
+-- name: GetPostRevisions :many
+SELECT *
+FROM post_revisions
+WHERE post_id = ?
+ORDER BY created_at ASC;
+
+-- name: AdoptLegacyPost :exec
+-- Posts saved before GUIDs were tracked carry the fallback identity. When
+-- their item shows up with a real GUID, switch the row over instead of
+-- inserting the story a second time.
+UPDATE posts
+SET
+    guid = sqlc.arg(guid),
+    updated_at = datetime('now')
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = sqlc.arg(fallback_guid)
+  AND NOT EXISTS (
+      SELECT 1
+      FROM posts AS adopted
+      WHERE adopted.feed_id = sqlc.arg(feed_id)
+        AND adopted.guid = sqlc.arg(guid)
+  );
+
+-- name: GetPostsForUser :many
+-- Lists the posts of the feeds a user follows, like its PostgreSQL
+-- counterpart. feeds is a JSON array of feed names or URLs; an empty
+-- array means all followed feeds. sort is joined in as a column because
+-- sqlc can't bind arguments in ORDER BY. The sort time is not returned,
+-- since SQLite would hand it back untyped; callers derive it from the row.
+SELECT
+    posts.id,
+    posts.created_at,
+    posts.updated_at,
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    posts.feed_id,
+    posts.authors,
+    feeds.name AS feed_name,
+    post_reads.read_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+JOIN (SELECT CAST(sqlc.arg(sort) AS TEXT) AS sort) AS options
+LEFT JOIN post_reads
+    ON post_reads.post_id = posts.id
+   AND post_reads.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (NOT CAST(sqlc.arg(unread_only) AS BOOLEAN) OR post_reads.read_at IS NULL)
+  AND (
+      json_array_length(CAST(sqlc.arg(feeds) AS TEXT)) = 0
+      OR feeds.name IN (SELECT value FROM json_each(sqlc.arg(feeds)))
+      OR feeds.url IN (SELECT value FROM json_each(sqlc.arg(feeds)))
+  )
+  AND (sqlc.narg(since) IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+  AND (sqlc.narg(until) IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+  AND (
+      sqlc.narg(after_id) IS NULL
+      OR (
+          options.sort = 'feed'
+          AND (
+              feeds.name > sqlc.narg(after_feed)
+              OR (
+                  feeds.name = sqlc.narg(after_feed)
+                  AND (COALESCE(posts.published_at, posts.created_at), posts.id)
+                      < (sqlc.narg(after_time), sqlc.narg(after_id))
+              )
+          )
+      )
+      OR (
+          options.sort = 'fetched'
+          AND (posts.created_at, posts.id) < (sqlc.narg(after_time), sqlc.narg(after_id))
+      )
+      OR (
+          options.sort = 'published'
+          AND (COALESCE(posts.published_at, posts.created_at), posts.id)
+              < (sqlc.narg(after_time), sqlc.narg(after_id))
+      )
+  )
+ORDER BY
+    CASE WHEN options.sort = 'feed' THEN feeds.name END ASC,
+    CASE WHEN options.sort = 'fetched' THEN posts.created_at ELSE COALESCE(posts.published_at, posts.created_at) END DESC,
+    posts.id DESC
+LIMIT sqlc.arg('limit')
+OFFSET sqlc.arg('offset');
+
+-- name: MarkPostRead :exec
+INSERT INTO post_reads (user_id, post_id, read_at)
+VALUES (?, ?, datetime('now'))
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkAllPostsRead :execrows
+INSERT INTO post_reads (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, datetime('now')
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = ?
+ON CONFLICT (user_id, post_id) DO NOTHING;
+
+-- name: MarkPostUnread :execrows
+DELETE FROM post_reads
+WHERE user_id = ?
+  AND post_id = ?;
+
+-- name: GetFeedPostingStats :one
+-- Average gap between the feed's most recent posts, used to adapt how
+-- often it is polled. 0 when there are fewer than two dated posts.
+SELECT
+    CAST(COALESCE(
+        (julianday(MAX(recent.published_at)) - julianday(MIN(recent.published_at))) * 86400
+            / NULLIF(COUNT(*) - 1, 0),
+        0
+    ) AS INTEGER) AS average_gap_seconds
+FROM (
+    SELECT published_at
+    FROM posts
+    WHERE feed_id = ?
+      AND published_at IS NOT NULL
+    ORDER BY published_at DESC
+    LIMIT 20
+) AS recent;
+
+-- name: SearchPostsForUser :many
+-- Full-text search over the posts of the feeds a user follows, using the
+-- FTS5 index. query is in FTS5 syntax. Titles weigh more than
+-- descriptions; rank is the negated bm25 score, so higher is better.
+SELECT
+    posts.id,
+    posts.title,
+    posts.url,
+    posts.published_at,
+    posts.created_at,
+    feeds.name AS feed_name,
+    CAST(-bm25(posts_fts, 10.0, 1.0) AS REAL) AS "rank",
+    CAST(snippet(posts_fts, -1, '**', '**', '...', 20) AS TEXT) AS snippet
+FROM posts_fts(CAST(sqlc.arg(query) AS TEXT))
+JOIN posts ON posts.seq = posts_fts.rowid
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (CAST(sqlc.narg(feed) AS TEXT) IS NULL OR feeds.name = sqlc.narg(feed) OR feeds.url = sqlc.narg(feed))
+  AND (sqlc.narg(since) IS NULL OR COALESCE(posts.published_at, posts.created_at) >= sqlc.narg(since))
+  AND (sqlc.narg(until) IS NULL OR COALESCE(posts.published_at, posts.created_at) < sqlc.narg(until))
+ORDER BY "rank" DESC, COALESCE(posts.published_at, posts.created_at) DESC
+LIMIT sqlc.arg('limit');
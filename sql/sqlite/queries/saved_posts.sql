@@ -0,0 +1,50 @@
+-- name: CreateSavedPost :one
+-- Saving an already saved post keeps it, replacing the note if a new one
+-- is given.
+INSERT INTO saved_posts (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    post_id,
+    note,
+    title,
+    url,
+    description,
+    published_at,
+    feed_name
+)
+SELECT
+    sqlc.arg(id),
+    datetime('now'),
+    datetime('now'),
+    sqlc.arg(user_id),
+    posts.id,
+    sqlc.narg(note),
+    posts.title,
+    posts.url,
+    posts.description,
+    posts.published_at,
+    feeds.name
+FROM posts
+JOIN feeds ON feeds.id = posts.feed_id
+WHERE posts.id = sqlc.arg(post_id)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET
+    note = COALESCE(excluded.note, saved_posts.note),
+    updated_at = datetime('now')
+RETURNING *;
+
+-- name: DeleteSavedPost :execrows
+-- Accepts either the post's id or, for posts that no longer exist, the
+-- id of the saved copy.
+DELETE FROM saved_posts
+WHERE user_id = sqlc.arg(user_id)
+  AND (post_id = sqlc.arg(id) OR id = sqlc.arg(id));
+
+-- name: GetSavedPostsForUser :many
+SELECT *
+FROM saved_posts
+WHERE user_id = ?
+ORDER BY created_at DESC
+LIMIT ?;
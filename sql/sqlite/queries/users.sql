@@ -0,0 +1,16 @@
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, name)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserByName :one
+SELECT id, name, created_at, updated_at
+FROM users
+WHERE name = ?;
+
+-- name: DeleteAllUsers :exec
+DELETE FROM users;
+
+-- name: GetUsers :many
+SELECT *
+FROM users;
@@ -0,0 +1,162 @@
+-- +goose Up
+-- The SQLite schema matches the PostgreSQL one after all of its
+-- migrations. Timestamps are stored as UTC text in SQLite's own
+-- 'YYYY-MM-DD HH:MM:SS' format, so they compare correctly with each
+-- other and with datetime('now').
+CREATE TABLE users (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL UNIQUE
+);
+
+CREATE TABLE feeds (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    name TEXT NOT NULL,
+    url TEXT NOT NULL UNIQUE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    last_fetched_at TIMESTAMP,
+    etag TEXT,
+    last_modified TEXT,
+    claimed_until TIMESTAMP,
+    last_error TEXT,
+    last_error_at TIMESTAMP,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    disabled_at TIMESTAMP,
+    disabled_reason TEXT,
+    next_fetch_at TIMESTAMP,
+    fetch_interval_seconds INTEGER,
+    hinted_interval_seconds INTEGER
+);
+
+CREATE INDEX feeds_next_fetch_at_idx ON feeds (next_fetch_at);
+
+CREATE TABLE feed_aliases (
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE
+);
+
+CREATE TABLE feed_follows (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    UNIQUE (user_id, feed_id)
+);
+
+-- seq is the rowid that posts_fts refers to. Declaring it keeps VACUUM
+-- from renumbering it.
+CREATE TABLE posts (
+    seq INTEGER PRIMARY KEY,
+    id UUID NOT NULL UNIQUE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    guid TEXT NOT NULL,
+    content_hash TEXT,
+    authors TEXT,
+    UNIQUE (feed_id, guid)
+);
+
+CREATE TABLE post_revisions (
+    id UUID PRIMARY KEY,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    content_hash TEXT NOT NULL
+);
+
+CREATE INDEX post_revisions_post_id_idx ON post_revisions (post_id, created_at);
+
+CREATE TABLE feed_fetches (
+    id UUID PRIMARY KEY,
+    feed_id UUID NOT NULL REFERENCES feeds(id) ON DELETE CASCADE,
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL,
+    items INTEGER NOT NULL,
+    inserted INTEGER NOT NULL,
+    updated INTEGER NOT NULL,
+    duplicates INTEGER NOT NULL,
+    failed INTEGER NOT NULL,
+    error TEXT
+);
+
+CREATE INDEX feed_fetches_feed_id_idx ON feed_fetches (feed_id, started_at DESC);
+
+CREATE TABLE post_reads (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID NOT NULL REFERENCES posts(id) ON DELETE CASCADE,
+    read_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, post_id)
+);
+
+CREATE TABLE saved_posts (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    post_id UUID REFERENCES posts(id) ON DELETE SET NULL,
+    note TEXT,
+    title TEXT NOT NULL,
+    url TEXT NOT NULL,
+    description TEXT,
+    published_at TIMESTAMP,
+    feed_name TEXT NOT NULL,
+    UNIQUE (user_id, post_id)
+);
+
+-- Full-text index over posts, kept in sync by triggers. The porter
+-- tokenizer stems English words like PostgreSQL's 'english' config.
+CREATE VIRTUAL TABLE posts_fts USING fts5(
+    title,
+    description,
+    content='posts',
+    content_rowid='seq',
+    tokenize='porter unicode61'
+);
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+    INSERT INTO posts_fts (rowid, title, description)
+    VALUES (new.seq, new.title, coalesce(new.description, ''));
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, description)
+    VALUES ('delete', old.seq, old.title, coalesce(old.description, ''));
+END;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, description ON posts BEGIN
+    INSERT INTO posts_fts (posts_fts, rowid, title, description)
+    VALUES ('delete', old.seq, old.title, coalesce(old.description, ''));
+    INSERT INTO posts_fts (rowid, title, description)
+    VALUES (new.seq, new.title, coalesce(new.description, ''));
+END;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE posts_fts;
+DROP TABLE saved_posts;
+DROP TABLE post_reads;
+DROP TABLE feed_fetches;
+DROP TABLE post_revisions;
+DROP TABLE posts;
+DROP TABLE feed_follows;
+DROP TABLE feed_aliases;
+DROP TABLE feeds;
+DROP TABLE users;
@@ -0,0 +1,10 @@
+// Package schema holds the goose migrations for the SQLite schema,
+// embedded so that gator can apply them itself.
+package schema
+
+import "embed"
+
+// FS contains the migration files.
+//
+//go:embed *.sql
+var FS embed.FS
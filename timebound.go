@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseTimeBound parses the value of a --since or --until flag. It accepts
+// either an age relative to now, as a Go duration or a number of days or
+// weeks ("36h", "7d", "2w"), or an absolute date or time ("2024-05-01",
+// "2024-05-01 14:00", RFC 3339), read in the local time zone.
+func parseTimeBound(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	if age, ok := parseAge(value); ok {
+		return now.Add(-age), nil
+	}
+
+	layouts := []string{
+		time.RFC3339,
+		"2006-01-02T15:04",
+		"2006-01-02 15:04",
+		"2006-01-02",
+	}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q: use a duration like 36h, 7d or 2w, or a date like 2024-05-01", value)
+}
+
+// parseAge parses a non-negative duration, adding the "d" and "w" units
+// time.ParseDuration lacks.
+func parseAge(value string) (time.Duration, bool) {
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return d, true
+	}
+
+	units := map[string]time.Duration{
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+	for suffix, unit := range units {
+		n, found := strings.CutSuffix(value, suffix)
+		if !found {
+			continue
+		}
+		if count, err := strconv.Atoi(n); err == nil && count >= 0 {
+			return time.Duration(count) * unit, true
+		}
+	}
+	return 0, false
+}